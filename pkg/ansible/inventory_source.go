@@ -0,0 +1,271 @@
+package ansible
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NewInventoryFromJSON parses the inventory in the JSON format emitted by ansible dynamic inventory scripts (`--list`).
+// The hostvars are read from the "_meta" group, the "all" group is ignored.
+//
+//	{
+//	  "web": {"hosts": ["10.0.0.1"], "vars": {"http_port": 80}, "children": ["nginx"]},
+//	  "nginx": ["10.0.0.2"],
+//	  "_meta": {"hostvars": {"10.0.0.1": {"rack": "r1"}}}
+//	}
+func NewInventoryFromJSON(b []byte) (*Inventory, error) {
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("unmarshal json inventory failed, err: %s", err)
+	}
+
+	meta := struct {
+		HostVars map[string]map[string]interface{} `json:"hostvars"`
+	}{}
+	if raw, ok := m[MetaGroupName]; ok {
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil, fmt.Errorf("unmarshal (%s) group failed, err: %s", MetaGroupName, err)
+		}
+	}
+
+	i := NewInventory()
+	for groupName, raw := range m {
+		if groupName == MetaGroupName || groupName == AllGroupName {
+			continue
+		}
+
+		g := struct {
+			Hosts    []string               `json:"hosts"`
+			Vars     map[string]interface{} `json:"vars"`
+			Children []string               `json:"children"`
+		}{}
+		// the group can be a list of hosts only
+		if err := json.Unmarshal(raw, &g.Hosts); err != nil {
+			if err := json.Unmarshal(raw, &g); err != nil {
+				return nil, fmt.Errorf("unmarshal group (%s) failed, err: %s", groupName, err)
+			}
+		}
+
+		group := NewGroup(groupName)
+		for _, host := range g.Hosts {
+			group.AddHost(host)
+			group.SetHostVars(host, meta.HostVars[host])
+		}
+		group.AddVars(g.Vars)
+		for _, child := range g.Children {
+			group.AddChildGroup(NewGroup(child))
+		}
+		i.SetGroup(group)
+	}
+
+	return i, nil
+}
+
+// NewInventoryFromINI parses the inventory in the INI format of ansible.
+// The host ranges like `web[01:10]` are not supported.
+//
+//	10.0.0.9
+//	[web]
+//	10.0.0.1 http_port=80
+//	[web:vars]
+//	ansible_user=deploy
+//	[nginx:children]
+//	web
+func NewInventoryFromINI(b []byte) (*Inventory, error) {
+	i := NewInventory()
+	getGroup := func(groupName string) *Group {
+		if group, err := i.GetGroup(groupName); err == nil {
+			return group
+		}
+		group := NewGroup(groupName)
+		i.SetGroup(group)
+		return group
+	}
+
+	groupName, section := UngroupedGroupName, "hosts"
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			groupName, section = strings.Trim(line, "[]"), "hosts"
+			if s := strings.SplitN(groupName, ":", 2); len(s) == 2 {
+				groupName, section = s[0], s[1]
+			}
+			if section != "hosts" && section != "vars" && section != "children" {
+				return nil, fmt.Errorf("line %d: unknown section (%s)", n, section)
+			}
+			getGroup(groupName)
+			continue
+		}
+
+		fields, err := splitINIFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+
+		group := getGroup(groupName)
+		switch section {
+		case "hosts":
+			host := fields[0]
+			group.AddHost(host)
+			vars, err := parseINIVars(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+			group.SetHostVars(host, vars)
+		case "vars":
+			vars, err := parseINIVars([]string{line})
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+			group.AddVars(vars)
+		case "children":
+			group.AddChildGroup(NewGroup(fields[0]))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// drop the implicit ungrouped group if it has no hosts
+	if group, err := i.GetGroup(UngroupedGroupName); err == nil && len(*group.Hosts) == 0 {
+		i.RemoveGroup(UngroupedGroupName)
+	}
+	i.RemoveGroup(AllGroupName)
+
+	return i, nil
+}
+
+// splitINIFields splits the line by spaces, the spaces in quoted values are kept.
+func splitINIFields(line string) ([]string, error) {
+	fields := []string{}
+	var sb strings.Builder
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			sb.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ' ' || r == '\t':
+			if sb.Len() != 0 {
+				fields = append(fields, sb.String())
+				sb.Reset()
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if sb.Len() != 0 {
+		fields = append(fields, sb.String())
+	}
+	return fields, nil
+}
+
+func parseINIVars(fields []string) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	for _, field := range fields {
+		s := strings.SplitN(field, "=", 2)
+		if len(s) != 2 {
+			return nil, fmt.Errorf("invalid variable (%s), must be in the form of key=value", field)
+		}
+		vars[strings.TrimSpace(s[0])] = strings.Trim(strings.TrimSpace(s[1]), `"'`)
+	}
+	return vars, nil
+}
+
+// NewInventoryFromCSV parses the inventory from the CSV file with a header line.
+// The "host" column is required, the "groups" column holds the groups of the host separated by ";",
+// the hosts without groups are put into the "ungrouped" group, and the other columns are used as hostvars.
+//
+//	host,groups,rack
+//	10.0.0.1,web;nginx,r1
+func NewInventoryFromCSV(b []byte) (*Inventory, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header failed, err: %s", err)
+	}
+	hostColumn, groupsColumn := -1, -1
+	for n, name := range header {
+		header[n] = strings.TrimSpace(name)
+		switch header[n] {
+		case "host":
+			hostColumn = n
+		case "groups":
+			groupsColumn = n
+		}
+	}
+	if hostColumn < 0 {
+		return nil, errors.New("not found the host column in csv header")
+	}
+
+	i := NewInventory()
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv failed, err: %s", err)
+		}
+
+		host := strings.TrimSpace(record[hostColumn])
+		if host == "" {
+			continue
+		}
+
+		vars := make(map[string]interface{})
+		for n, value := range record {
+			if n == hostColumn || n == groupsColumn || value == "" {
+				continue
+			}
+			vars[header[n]] = value
+		}
+
+		groupNames := []string{}
+		if groupsColumn >= 0 {
+			for _, groupName := range strings.Split(record[groupsColumn], ";") {
+				if groupName = strings.TrimSpace(groupName); groupName != "" {
+					groupNames = append(groupNames, groupName)
+				}
+			}
+		}
+		if len(groupNames) == 0 {
+			groupNames = append(groupNames, UngroupedGroupName)
+		}
+
+		for _, groupName := range groupNames {
+			group, err := i.GetGroup(groupName)
+			if err != nil {
+				group = NewGroup(groupName)
+				i.SetGroup(group)
+			}
+			group.AddHost(host)
+			group.SetHostVars(host, vars)
+		}
+	}
+
+	return i, nil
+}
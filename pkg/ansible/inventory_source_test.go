@@ -0,0 +1,93 @@
+package ansible
+
+import (
+	"testing"
+)
+
+func TestNewInventoryFromJSON(t *testing.T) {
+	data := `{
+		"web": {"hosts": ["10.0.0.1"], "vars": {"http_port": 80}, "children": ["nginx"]},
+		"nginx": ["10.0.0.2"],
+		"all": {"vars": {"ansible_user": "root"}},
+		"_meta": {"hostvars": {"10.0.0.1": {"rack": "r1"}}}
+	}`
+
+	i, err := NewInventoryFromJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if i.HasGroup(AllGroupName) {
+		t.Errorf("the all group should be ignored")
+	}
+	web, err := i.GetGroup("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !web.HasHost("10.0.0.1") || (*web.Hosts)["10.0.0.1"]["rack"] != "r1" {
+		t.Errorf("unexpected hosts of web group: %v", web.Hosts)
+	}
+	if !i.HasGroup("nginx") {
+		t.Errorf("not found nginx group")
+	}
+}
+
+func TestNewInventoryFromINI(t *testing.T) {
+	data := `
+10.0.0.9
+[web]
+10.0.0.1 http_port=80 motd="hello world"
+[web:vars]
+ansible_user=deploy
+[nginx:children]
+web
+`
+
+	i, err := NewInventoryFromINI([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web, err := i.GetGroup("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*web.Hosts)["10.0.0.1"]["motd"] != "hello world" {
+		t.Errorf("unexpected hostvars: %v", (*web.Hosts)["10.0.0.1"])
+	}
+	if (*web.Vars)["ansible_user"] != "deploy" {
+		t.Errorf("unexpected vars: %v", web.Vars)
+	}
+	ungrouped, err := i.GetGroup(UngroupedGroupName)
+	if err != nil || !ungrouped.HasHost("10.0.0.9") {
+		t.Errorf("not found 10.0.0.9 in ungrouped group")
+	}
+
+	if _, err := NewInventoryFromINI([]byte("[web:foo]\n")); err == nil {
+		t.Errorf("expected error for unknown section")
+	}
+}
+
+func TestNewInventoryFromCSV(t *testing.T) {
+	data := "host,groups,rack\n10.0.0.1,web;nginx,r1\n10.0.0.2,,r2\n"
+
+	i, err := NewInventoryFromCSV([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, groupName := range []string{"web", "nginx"} {
+		group, err := i.GetGroup(groupName)
+		if err != nil || !group.HasHost("10.0.0.1") {
+			t.Errorf("not found 10.0.0.1 in group (%s)", groupName)
+		}
+	}
+	ungrouped, err := i.GetGroup(UngroupedGroupName)
+	if err != nil || (*ungrouped.Hosts)["10.0.0.2"]["rack"] != "r2" {
+		t.Errorf("unexpected ungrouped group")
+	}
+
+	if _, err := NewInventoryFromCSV([]byte("name,groups\n")); err == nil {
+		t.Errorf("expected error for missing host column")
+	}
+}
@@ -0,0 +1,75 @@
+package ansible
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// VaultHeader is the header of the content encrypted by ansible-vault.
+	VaultHeader = "$ANSIBLE_VAULT;"
+
+	// VaultTag is the yaml tag of the values encrypted by `ansible-vault encrypt_string`.
+	VaultTag = "!vault"
+)
+
+// IsVaulted returns whether the whole content is encrypted by ansible-vault.
+func IsVaulted(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte(VaultHeader))
+}
+
+// VaultString is a value encrypted by ansible-vault.
+// It is encoded as a `!vault` tagged literal block, so ansible-playbook can decrypt it.
+type VaultString string
+
+func (v VaultString) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   VaultTag,
+		Style: yaml.LiteralStyle,
+		Value: string(v),
+	}, nil
+}
+
+// MarkVaulted returns v with all the vaulted string values in it converted to VaultString.
+// The yaml decoder drops the `!vault` tag of the values, without converting,
+// the vaulted values would be encoded as plain strings which ansible-playbook can not decrypt.
+func MarkVaulted(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case string:
+		if strings.HasPrefix(vv, VaultHeader) {
+			return VaultString(vv)
+		}
+	case map[string]interface{}:
+		for k, item := range vv {
+			vv[k] = MarkVaulted(item)
+		}
+	case []interface{}:
+		for i, item := range vv {
+			vv[i] = MarkVaulted(item)
+		}
+	}
+	return v
+}
+
+// MarkVaulted converts the vaulted values in the host vars and group vars of the inventory to VaultString.
+func (i *Inventory) MarkVaulted() {
+	for _, group := range i.GroupsMap {
+		if group == nil {
+			continue
+		}
+		if group.Hosts != nil {
+			for _, hostVars := range *group.Hosts {
+				MarkVaulted(hostVars)
+			}
+		}
+		if group.Vars != nil {
+			MarkVaulted(*group.Vars)
+		}
+		if group.Children != nil {
+			group.Children.MarkVaulted()
+		}
+	}
+}
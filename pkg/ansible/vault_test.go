@@ -0,0 +1,47 @@
+package ansible
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestMarkVaulted(t *testing.T) {
+	data := `a: 1
+m:
+    list:
+        - !vault |
+          $ANSIBLE_VAULT;1.1;AES256
+          3536
+pass: !vault |
+    $ANSIBLE_VAULT;1.1;AES256
+    3334
+`
+
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(data), &m); err != nil {
+		t.Fatal(err)
+	}
+	MarkVaulted(m)
+
+	if _, ok := m["pass"].(VaultString); !ok {
+		t.Errorf("expected pass to be VaultString, got %T", m["pass"])
+	}
+
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != data {
+		t.Errorf("expected:\n%s\ngot:\n%s", data, string(b))
+	}
+}
+
+func TestIsVaulted(t *testing.T) {
+	if !IsVaulted([]byte("\n$ANSIBLE_VAULT;1.1;AES256\n3334\n")) {
+		t.Error("expected vaulted")
+	}
+	if IsVaulted([]byte("a: $ANSIBLE_VAULT;1.1;AES256\n")) {
+		t.Error("expected not vaulted")
+	}
+}
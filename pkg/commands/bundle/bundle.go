@@ -17,8 +17,8 @@ func NewCmdBundle(sailOption *models.SailOption) *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "bundle",
-		Short: "bundle packages of a product",
-		Long:  "bundle packages of a product",
+		Short: "bundle operation code and packages of a product into an offline archive",
+		Long:  "bundle operation code and packages of a product into an offline archive",
 		Run: func(cmd *cobra.Command, args []string) {
 			common.CheckErr(o.Complete(cmd, args))
 			common.CheckErr(o.Validate())
@@ -28,7 +28,8 @@ func NewCmdBundle(sailOption *models.SailOption) *cobra.Command {
 
 	defaultProductName := ""
 	cmd.Flags().StringVarP(&o.productName, "product", "p", defaultProductName, "the product name")
-	_ = cmd.MarkFlagRequired("playbook")
+	_ = cmd.MarkFlagRequired("product")
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "the output bundle file, default <product>.bundle.tar.gz")
 
 	return cmd
 }
@@ -36,6 +37,7 @@ func NewCmdBundle(sailOption *models.SailOption) *cobra.Command {
 type BundleOptions struct {
 	productName string
 	productDir  string
+	output      string
 
 	sailOption *models.SailOption
 }
@@ -57,6 +59,10 @@ func (o *BundleOptions) Complete(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("not found dir of product, %s does not exist", o.productDir)
 	}
 
+	if o.output == "" {
+		o.output = o.productName + ".bundle.tar.gz"
+	}
+
 	return nil
 }
 
@@ -70,11 +76,16 @@ func (o *BundleOptions) Run() error {
 		return fmt.Errorf("product init failed, err: %s", err)
 	}
 
-	components := product.ComponentList()
-	fmt.Printf("the product %s contains (%d) components:\n", o.productName, len(components))
-	for _, c := range components {
-		fmt.Printf("- %s\n", c)
+	manifest, err := product.Bundle(o.sailOption.PackagesDir, o.output)
+	if err != nil {
+		return fmt.Errorf("bundle product failed, err: %s", err)
+	}
+
+	var size int64
+	for _, f := range manifest.Files {
+		size += f.Size
 	}
+	fmt.Printf("bundled product %s (%d files, %d bytes) into %s\n", o.productName, len(manifest.Files), size, o.output)
 
 	return nil
 }
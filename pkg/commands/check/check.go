@@ -0,0 +1,90 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdCheck(sailOption *models.SailOption) *cobra.Command {
+	o := NewCheckOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "check packages, roles and charts required by the zone before apply",
+		Long:  "check packages, roles and charts required by the zone before apply",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+
+	return cmd
+}
+
+type CheckOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+
+	sailOption *models.SailOption
+}
+
+func NewCheckOptions(sailOption *models.SailOption) *CheckOptions {
+	return &CheckOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *CheckOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *CheckOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	return nil
+}
+
+func (o *CheckOptions) Run() error {
+	options.PrintColorHeader(o.TargetName, o.ZoneName)
+
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	if err := zone.LoadConf(); err != nil {
+		return fmt.Errorf("zone.LoadConf failed, err: %s", err)
+	}
+	if err := zone.LoadInventorySources(); err != nil {
+		return err
+	}
+
+	problems := zone.PreflightCheck()
+	if len(problems) == 0 {
+		fmt.Println("✅ no problems found")
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("❌ %s\n", problem)
+	}
+
+	return fmt.Errorf("found (%d) problems", len(problems))
+}
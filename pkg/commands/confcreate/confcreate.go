@@ -3,6 +3,7 @@ package confcreate
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/bougou/gopkg/common"
 	"github.com/bougou/sail/pkg/models"
@@ -49,11 +50,14 @@ func NewCmdConfCreate(sailOption *models.SailOption) *cobra.Command {
 	cmd.Flags().IntVar(&o.SSHPort, "ssh-port", defaultSSHPort, "the ssh port")
 
 	cmd.Flags().StringArrayVarP(&o.Hosts, "hosts", "", o.Hosts, "the hosts")
+	cmd.Flags().StringArrayVarP(&o.Labels, "label", "", o.Labels, "the labels of the zone (eg: 'region=east,tier=canary')")
 
 	cmd.Flags().StringVar(&o.KubeConfig, "kubeconfig", defaultKubeConfig, "path to the kubeconfig file")
 	cmd.Flags().StringVar(&o.KubeContext, "kube-context", defaultKubeContext, "name of the kubeconfig context to use")
 	cmd.Flags().StringVar(&o.Namespace, "namespace", defaultNamespace, "k8s namespace scope")
 
+	cmd.Flags().BoolVarP(&o.IgnorePortsConflict, "ignore-ports-conflict", "", o.IgnorePortsConflict, "continue even if the ports of components are conflicted on same hosts")
+
 	return cmd
 }
 
@@ -67,12 +71,15 @@ type ConfCreateOptions struct {
 	SSHUser    string
 	SSHPort    int
 
-	Hosts []string
+	Hosts  []string
+	Labels []string
 
 	KubeConfig  string
 	KubeContext string
 	Namespace   string
 
+	IgnorePortsConflict bool
+
 	sailOption *models.SailOption
 }
 
@@ -102,9 +109,18 @@ func (o *ConfCreateOptions) Run() error {
 		return fmt.Errorf("target/zone (%s/%s) already exists, found zone dir: %s, remove the dir if you want to recreate the zone", o.TargetName, o.ZoneName, zone.ZoneDir)
 	}
 
+	labels, removed, err := options.ParseLabelsOption(o.Labels)
+	if err != nil {
+		return fmt.Errorf("parse label option failed, err: %s", err)
+	}
+	if len(removed) != 0 {
+		return fmt.Errorf("can not remove labels (%s) when create a zone", strings.Join(removed, ","))
+	}
+
 	zone.ZoneMeta = &target.ZoneMeta{
 		SailProduct:  o.ProductName,
 		SailHelmMode: "component",
+		SailLabels:   labels,
 	}
 
 	if err := zone.LoadNew(); err != nil {
@@ -129,6 +145,13 @@ func (o *ConfCreateOptions) Run() error {
 		return err
 	}
 
+	if err := zone.CheckPortsConflict(); err != nil {
+		if !o.IgnorePortsConflict {
+			return fmt.Errorf("%s\nfix the conflicts, or specify --ignore-ports-conflict to continue", err)
+		}
+		fmt.Printf("warn: %s\n", err)
+	}
+
 	if err := zone.Dump(); err != nil {
 		return fmt.Errorf("dump zone failed, err: %s", err)
 	}
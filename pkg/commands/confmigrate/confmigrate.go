@@ -0,0 +1,96 @@
+package confmigrate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdConfMigrate(sailOption *models.SailOption) *cobra.Command {
+	o := NewConfMigrateOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "conf-migrate",
+		Short: "migrate the vars of an environment according to the migrate.yaml of the product",
+		Long:  "migrate the vars of an environment according to the migrate.yaml of the product",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+
+	return cmd
+}
+
+type ConfMigrateOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+
+	sailOption *models.SailOption
+}
+
+func NewConfMigrateOptions(sailOption *models.SailOption) *ConfMigrateOptions {
+	return &ConfMigrateOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *ConfMigrateOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *ConfMigrateOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	return nil
+}
+
+func (o *ConfMigrateOptions) Run() error {
+	options.PrintColorHeader(o.TargetName, o.ZoneName)
+
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	applied, err := zone.HandleCompatibity()
+	if err != nil {
+		return fmt.Errorf("migrate zone failed, err: %s", err)
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("zone is up to date, no migrations applied")
+		return nil
+	}
+
+	for _, version := range applied {
+		fmt.Printf("applied migration (%d)\n", version)
+	}
+
+	// load and dump the zone to normalize the migrated files
+	if err := zone.Load(); err != nil {
+		return fmt.Errorf("zone.Load failed, err: %s", err)
+	}
+
+	if err := zone.Dump(); err != nil {
+		return fmt.Errorf("zone.Dump failed, err: %s", err)
+	}
+
+	return nil
+}
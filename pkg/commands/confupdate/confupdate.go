@@ -3,6 +3,7 @@ package confupdate
 import (
 	"errors"
 	"fmt"
+	"os"
 
 	"github.com/bougou/gopkg/common"
 	"github.com/bougou/sail/pkg/models/target"
@@ -29,27 +30,43 @@ func NewCmdConfUpdate(sailOption *models.SailOption) *cobra.Command {
 	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
 
 	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+	cmd.Flags().StringArrayVarP(&o.Zones, "zones", "", o.Zones, "choose the zones matching the glob patterns (eg: 'prod-*'), no meaning if explicitly specified a zone")
+	cmd.Flags().StringVarP(&o.Selector, "selector", "l", o.Selector, "choose the zones matching the label selector (eg: 'tier=canary'), no meaning if explicitly specified a zone")
 
 	cmd.Flags().StringArrayVarP(&o.Hosts, "hosts", "", nil, "the hosts")
+	cmd.Flags().StringArrayVarP(&o.Labels, "label", "", nil, "set the labels of the zone (eg: 'tier=canary'), or remove a label by 'key-'")
 	cmd.Flags().StringArrayVarP(&o.Components, "components", "c", nil, "enable components")
 	cmd.Flags().StringArrayVarP(&o.NoComponents, "no-components", "", nil, "disable components")
 	cmd.Flags().StringArrayVarP(&o.ExternalComponents, "external-components", "", nil, "enable external components")
 	cmd.Flags().StringArrayVarP(&o.NoExternalComponents, "no-external-components", "", nil, "disable external components")
 
+	cmd.Flags().StringVarP(&o.Requires, "requires", "", RequiresPrompt, "how to activate the required components of the enabled components, valid values: prompt, enable, external")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", o.DryRun, "print the resulting activation set of components and the diff of zone files without writing any files")
+
+	cmd.Flags().BoolVarP(&o.IgnorePortsConflict, "ignore-ports-conflict", "", o.IgnorePortsConflict, "continue even if the ports of components are conflicted on same hosts")
+
 	return cmd
 }
 
 type ConfUpdateOptions struct {
 	TargetName string
 	ZoneName   string
+	Zones      []string
+	Selector   string
 
-	Hosts []string
+	Hosts  []string
+	Labels []string
 
 	Components           []string
 	NoComponents         []string
 	ExternalComponents   []string
 	NoExternalComponents []string
 
+	Requires string
+	DryRun   bool
+
+	IgnorePortsConflict bool
+
 	sailOption *models.SailOption
 }
 
@@ -63,7 +80,8 @@ func (o *ConfUpdateOptions) Complete(cmd *cobra.Command, args []string) error {
 	if o.TargetName == "" {
 		o.TargetName = o.sailOption.DefaultTarget
 	}
-	if o.ZoneName == "" {
+	// the default zone is not used when choosing multiple zones
+	if o.ZoneName == "" && len(o.Zones) == 0 && o.Selector == "" {
 		o.ZoneName = o.sailOption.DefaultZone
 	}
 
@@ -74,19 +92,47 @@ func (o *ConfUpdateOptions) Validate() error {
 	if o.TargetName == "" {
 		return errors.New("must specify target name")
 	}
-	if o.ZoneName == "" {
-		return errors.New("must specify zone name")
+	if o.ZoneName == "" && len(o.Zones) == 0 && o.Selector == "" {
+		return errors.New("must specify zone name, or choose zones by specify '--zones' or '--selector' option")
+	}
+	switch o.Requires {
+	case RequiresPrompt, RequiresEnable, RequiresExternal:
+	default:
+		return fmt.Errorf("not supported --requires value (%s), valid values: prompt, enable, external", o.Requires)
 	}
 
 	return nil
 }
 
 func (o *ConfUpdateOptions) Run() error {
-	options.PrintColorHeader(o.TargetName, o.ZoneName)
+	if o.ZoneName != "" {
+		return o.run(o.ZoneName)
+	}
 
-	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
-	if err := zone.Load(); err != nil {
-		return fmt.Errorf("zone.Load failed, err: %s", err)
+	zoneNames, err := options.ChooseZones(o.sailOption, o.TargetName, o.Zones, o.Selector)
+	if err != nil {
+		return err
+	}
+
+	results := options.RunZones(zoneNames, options.OnErrorContinue, 1, func(zoneName string, zoneIO *options.ZoneIO) error {
+		return o.run(zoneName)
+	})
+	return options.PrintZoneResults(o.TargetName, results)
+}
+
+func (o *ConfUpdateOptions) run(zoneName string) error {
+	options.PrintColorHeader(o.TargetName, zoneName)
+
+	zone := target.NewZone(o.sailOption, o.TargetName, zoneName)
+	if o.DryRun {
+		// do not prepare helm charts to avoid writing files
+		if err := zone.LoadConf(); err != nil {
+			return fmt.Errorf("zone.LoadConf failed, err: %s", err)
+		}
+	} else {
+		if err := zone.Load(); err != nil {
+			return fmt.Errorf("zone.Load failed, err: %s", err)
+		}
 	}
 
 	m, err := options.ParseHostsOptions(o.Hosts)
@@ -97,10 +143,23 @@ func (o *ConfUpdateOptions) Run() error {
 		return fmt.Errorf("patch hosts failed, err: %s", err)
 	}
 
+	labels, removed, err := options.ParseLabelsOption(o.Labels)
+	if err != nil {
+		return fmt.Errorf("parse label option failed, err: %s", err)
+	}
+	if err := zone.SetLabels(labels, removed); err != nil {
+		return fmt.Errorf("set labels failed, err: %s", err)
+	}
+
+	before := activationSet(zone.Product)
+	activated := []string{}
+	deactivated := []string{}
+
 	if components, err := options.ParseComponentsOption(o.Components); err != nil {
 		return fmt.Errorf("parse component options failed, err: %s", err)
 	} else {
 		for c := range components {
+			activated = append(activated, c)
 			if err := zone.Product.SetComponentEnabled(c, true); err != nil {
 				return fmt.Errorf("update component enabled to true failed, err: %s", err)
 			}
@@ -111,6 +170,7 @@ func (o *ConfUpdateOptions) Run() error {
 		return fmt.Errorf("parse component options failed, err: %s", err)
 	} else {
 		for c := range components {
+			deactivated = append(deactivated, c)
 			if err := zone.Product.SetComponentEnabled(c, false); err != nil {
 				return fmt.Errorf("update component enabled to false failed, err: %s", err)
 			}
@@ -121,6 +181,7 @@ func (o *ConfUpdateOptions) Run() error {
 		return fmt.Errorf("parse component options failed, err: %s", err)
 	} else {
 		for c := range components {
+			activated = append(activated, c)
 			if err := zone.Product.SetComponentExternalEnabled(c, true); err != nil {
 				return fmt.Errorf("update component external to true failed, err: %s", err)
 			}
@@ -131,12 +192,33 @@ func (o *ConfUpdateOptions) Run() error {
 		return fmt.Errorf("parse component options failed, err: %s", err)
 	} else {
 		for c := range components {
+			deactivated = append(deactivated, c)
 			if err := zone.Product.SetComponentExternalEnabled(c, false); err != nil {
 				return fmt.Errorf("update component external to false failed, err: %s", err)
 			}
 		}
 	}
 
+	if err := checkDeactivated(zone.Product, deactivated); err != nil {
+		return err
+	}
+
+	if err := o.activateRequires(zone.Product, activated); err != nil {
+		return err
+	}
+
+	if o.DryRun {
+		printActivationSet(zone.Product, before)
+		return options.PrintZonePlan(os.Stdout, zone)
+	}
+
+	if err := zone.CheckPortsConflict(); err != nil {
+		if !o.IgnorePortsConflict {
+			return fmt.Errorf("%s\nfix the conflicts, or specify --ignore-ports-conflict to continue", err)
+		}
+		fmt.Printf("warn: %s\n", err)
+	}
+
 	if err := zone.Dump(); err != nil {
 		return fmt.Errorf("zone.Dump failed, err: %s", err)
 	}
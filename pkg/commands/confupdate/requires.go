@@ -0,0 +1,141 @@
+package confupdate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bougou/sail/pkg/models/product"
+)
+
+const (
+	RequiresPrompt   = "prompt"
+	RequiresEnable   = "enable"
+	RequiresExternal = "external"
+)
+
+const (
+	activationEnabled  = "enabled"
+	activationExternal = "external"
+	activationDisabled = "disabled"
+)
+
+// activationSet returns the activation state of all components of the product.
+func activationSet(p *product.Product) map[string]string {
+	out := make(map[string]string)
+	for componentName, c := range p.Components {
+		switch {
+		case c.Enabled:
+			out[componentName] = activationEnabled
+		case c.External:
+			out[componentName] = activationExternal
+		default:
+			out[componentName] = activationDisabled
+		}
+	}
+	return out
+}
+
+// checkDeactivated makes sure the deactivated components are not required by any active components.
+func checkDeactivated(p *product.Product, deactivated []string) error {
+	msgs := []string{}
+	sort.Strings(deactivated)
+
+	for _, componentName := range deactivated {
+		if p.Components[componentName].IsActive() {
+			continue
+		}
+
+		dependents, err := p.ActiveDependents(componentName)
+		if err != nil {
+			return fmt.Errorf("determine dependents of component (%s) failed, err: %s", componentName, err)
+		}
+		if len(dependents) != 0 {
+			msgs = append(msgs, fmt.Sprintf("component (%s) is still required by active components: %s", componentName, strings.Join(dependents, ", ")))
+		}
+	}
+
+	if len(msgs) != 0 {
+		return fmt.Errorf("can not deactivate components:\n%s", strings.Join(msgs, "\n"))
+	}
+	return nil
+}
+
+// activateRequires activates all required components of the activated components recursively.
+// The required component is enabled or marked as external according to the --requires option.
+// The requires are walked again after each component is activated, so the requires of the components
+// marked as external are not activated.
+func (o *ConfUpdateOptions) activateRequires(p *product.Product, activated []string) error {
+	sort.Strings(activated)
+
+	mode := o.Requires
+	if mode == RequiresPrompt && !isTerminal(os.Stdin) {
+		mode = RequiresEnable
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		inactive, err := p.InactiveRequires(activated...)
+		if err != nil {
+			return fmt.Errorf("determine required components failed, err: %s", err)
+		}
+		if len(inactive) == 0 {
+			return nil
+		}
+		componentName := inactive[0]
+
+		external := mode == RequiresExternal
+		if mode == RequiresPrompt {
+			fmt.Printf("component (%s) is required but not activated, enable it or mark it as external? [E/x]: ", componentName)
+			answer, err := reader.ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("read answer failed, err: %s", err)
+			}
+			external = strings.ToLower(strings.TrimSpace(answer)) == "x"
+		}
+
+		if external {
+			fmt.Printf("mark required component (%s) as external\n", componentName)
+			if err := p.SetComponentExternalEnabled(componentName, true); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fmt.Printf("enable required component (%s)\n", componentName)
+		if err := p.SetComponentEnabled(componentName, true); err != nil {
+			return err
+		}
+	}
+}
+
+// printActivationSet prints the activated components, and the changes compared to before.
+func printActivationSet(p *product.Product, before map[string]string) {
+	after := activationSet(p)
+
+	fmt.Println("activation set:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, componentName := range p.ComponentList() {
+		state := after[componentName]
+		change := ""
+		if before[componentName] != state {
+			change = fmt.Sprintf("(was %s)", before[componentName])
+		}
+		if state == activationDisabled && change == "" {
+			continue
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", componentName, state, change)
+	}
+	w.Flush()
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,160 @@
+package describe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func NewCmdDescribe(sailOption *models.SailOption) *cobra.Command {
+	o := NewDescribeOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "describe",
+		Short: "show the effective config of a component of the zone",
+		Long:  "show the effective config, the computed services and the inventory group of a component of the zone, each field is commented with its source",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+	cmd.Flags().StringVarP(&o.Component, "component", "c", o.Component, "the component, the version can be overridden like the other commands (eg: <componentName>/<componentVersion>)")
+	_ = cmd.MarkFlagRequired("component")
+
+	return cmd
+}
+
+type DescribeOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+	Component  string `json:"component"`
+
+	sailOption *models.SailOption
+}
+
+func NewDescribeOptions(sailOption *models.SailOption) *DescribeOptions {
+	return &DescribeOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *DescribeOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *DescribeOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	return nil
+}
+
+func (o *DescribeOptions) Run() error {
+	m, err := options.ParseComponentsOption([]string{o.Component})
+	if err != nil {
+		return fmt.Errorf("parse component option failed, err: %s", err)
+	}
+	if len(m) != 1 {
+		return fmt.Errorf("must specify exactly one component, got (%s)", o.Component)
+	}
+
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	if err := zone.LoadConf(); err != nil {
+		return fmt.Errorf("zone.LoadConf failed, err: %s", err)
+	}
+	if err := zone.LoadInventorySources(); err != nil {
+		return err
+	}
+
+	var componentName string
+	cliOverrides := []string{}
+	for name, version := range m {
+		componentName = name
+		if !zone.Product.HasComponent(componentName) {
+			return fmt.Errorf("component (%s) is not a valid component name for product (%s)", componentName, zone.Product.Name)
+		}
+		if version != "" {
+			if err := zone.SetComponentVersion(componentName, version); err != nil {
+				return err
+			}
+			cliOverrides = append(cliOverrides, "version")
+		}
+	}
+
+	if err := zone.Compute(); err != nil {
+		return fmt.Errorf("zone.Compute failed, err: %s", err)
+	}
+
+	sources, err := zone.ComponentSources(componentName, cliOverrides)
+	if err != nil {
+		return err
+	}
+
+	node, err := target.AnnotateSources(zone.Product.Components[componentName], sources)
+	if err != nil {
+		return fmt.Errorf("encode component failed, err: %s", err)
+	}
+
+	// the vars of the component and the inventory may contain secrets
+	out := zone.MaskSecrets(os.Stdout)
+	defer out.Close()
+
+	fmt.Fprintf(out, "# component (%s) of zone (%s/%s)\n", componentName, o.TargetName, o.ZoneName)
+	fmt.Fprintf(out, "# the source of each field: %s (product defaults), %s (zone vars.yaml), %s (command line override), %s (computed by sail)\n",
+		target.SourceProduct, target.SourceZone, target.SourceCLI, target.SourceComputed)
+	if err := printYAML(out, &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: componentName},
+			node,
+		},
+	}); err != nil {
+		return err
+	}
+
+	if zone.HasInventorySources() {
+		fmt.Fprintf(out, "\n# inventory group (%s) from hosts.yaml and the inventory sources\n", componentName)
+	} else {
+		fmt.Fprintf(out, "\n# inventory group (%s) from hosts.yaml\n", componentName)
+	}
+	if !zone.CMDB.Inventory.HasGroup(componentName) {
+		fmt.Fprintln(out, "# not found")
+		return nil
+	}
+	group, err := zone.CMDB.Inventory.GetGroup(componentName)
+	if err != nil {
+		return err
+	}
+	return printYAML(out, map[string]interface{}{componentName: group})
+}
+
+func printYAML(w io.Writer, v interface{}) error {
+	b, err := common.Encode("yaml", v)
+	if err != nil {
+		return fmt.Errorf("encode yaml failed, err: %s", err)
+	}
+	fmt.Fprint(w, string(b))
+	return nil
+}
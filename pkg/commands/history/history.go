@@ -0,0 +1,93 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdHistory(sailOption *models.SailOption) *cobra.Command {
+	o := NewHistoryOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "list the configuration snapshots of the zone",
+		Long:  "list the configuration snapshots of the zone, the snapshot is taken before the zone files are overwritten",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+
+	return cmd
+}
+
+type HistoryOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+
+	sailOption *models.SailOption
+}
+
+func NewHistoryOptions(sailOption *models.SailOption) *HistoryOptions {
+	return &HistoryOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *HistoryOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *HistoryOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	return nil
+}
+
+func (o *HistoryOptions) Run() error {
+	options.PrintColorHeader(o.TargetName, o.ZoneName)
+
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	snapshots, err := zone.Histories()
+	if err != nil {
+		return fmt.Errorf("list histories failed, err: %s", err)
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Println("no histories found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tFILES\tCOMMAND")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.ID, s.CreatedAt.Format("2006-01-02 15:04:05"), strings.Join(s.Files, ","), s.Command)
+	}
+	w.Flush()
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package hosts
+
+import (
+	"fmt"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/commands/hosts/sync"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdHosts(sailOption *models.SailOption) *cobra.Command {
+	o := NewHostsOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "hosts",
+		Short: "manage the hosts of zones",
+		Long:  "manage the hosts of zones",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run(args))
+		},
+	}
+
+	cmd.AddCommand(sync.NewCmdSync(o.sailOption))
+
+	return cmd
+}
+
+type HostsOptions struct {
+	sailOption *models.SailOption
+}
+
+func NewHostsOptions(sailOption *models.SailOption) *HostsOptions {
+	return &HostsOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *HostsOptions) Complete(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *HostsOptions) Validate() error {
+	return nil
+}
+
+func (o *HostsOptions) Run(args []string) error {
+	fmt.Println("specify a concret command under hosts")
+	return nil
+}
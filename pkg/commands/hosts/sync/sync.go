@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdSync(sailOption *models.SailOption) *cobra.Command {
+	o := NewSyncOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "freeze the hosts got from the inventory sources into hosts.yaml",
+		Long:  "freeze the hosts got from the inventory sources into hosts.yaml, and remove the inventory sources declaration, so the hosts of the zone are no longer changed by the sources",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", o.DryRun, "print the diff of zone files, without changing anything")
+
+	return cmd
+}
+
+type SyncOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+	DryRun     bool   `json:"dry_run"`
+
+	sailOption *models.SailOption
+}
+
+func NewSyncOptions(sailOption *models.SailOption) *SyncOptions {
+	return &SyncOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *SyncOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *SyncOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	return nil
+}
+
+func (o *SyncOptions) Run() error {
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	if err := zone.LoadConf(); err != nil {
+		return fmt.Errorf("zone.LoadConf failed, err: %s", err)
+	}
+	if err := zone.LoadInventorySources(); err != nil {
+		return err
+	}
+
+	if err := zone.FreezeInventorySources(); err != nil {
+		return err
+	}
+
+	if o.DryRun {
+		return options.PrintZonePlan(os.Stdout, zone)
+	}
+
+	if err := zone.Dump(); err != nil {
+		return fmt.Errorf("zone.Dump failed, err: %s", err)
+	}
+
+	fmt.Printf("the hosts got from the inventory sources are saved to %s\n", zone.HostsFile)
+	return nil
+}
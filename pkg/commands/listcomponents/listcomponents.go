@@ -3,12 +3,18 @@ package listcomponents
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/bougou/gopkg/common"
 	"github.com/bougou/sail/pkg/models"
 	"github.com/bougou/sail/pkg/models/product"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
 	"github.com/spf13/cobra"
 )
 
@@ -17,8 +23,8 @@ func NewCmdListComponents(sailOption *models.SailOption) *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "list-components",
-		Short: "list the components of a product",
-		Long:  "list the components of a product",
+		Short: "list the components of a product or a zone",
+		Long:  "list the components of a product with the default vars, or the effective components of a zone if target and zone specified",
 		Run: func(cmd *cobra.Command, args []string) {
 			common.CheckErr(o.Complete(cmd, args))
 			common.CheckErr(o.Validate())
@@ -28,7 +34,14 @@ func NewCmdListComponents(sailOption *models.SailOption) *cobra.Command {
 
 	defaultProductName := ""
 	cmd.Flags().StringVarP(&o.productName, "product", "p", defaultProductName, "the product name")
-	_ = cmd.MarkFlagRequired("playbook")
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", options.OutputTable, "output format, valid values: table, yaml, json")
+
+	cmd.Flags().BoolVarP(&o.Enabled, "enabled", "", o.Enabled, "only list the enabled components")
+	cmd.Flags().BoolVarP(&o.External, "external", "", o.External, "only list the external components")
+	cmd.Flags().StringVarP(&o.Form, "form", "", o.Form, "only list the components of the form, valid values: server, pod")
+	cmd.Flags().StringVarP(&o.Group, "group", "", o.Group, "only list the components of the group")
 
 	return cmd
 }
@@ -37,9 +50,34 @@ type ListComponentsOptions struct {
 	productName string
 	productDir  string
 
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+	Output     string `json:"output"`
+
+	Enabled  bool   `json:"enabled"`
+	External bool   `json:"external"`
+	Form     string `json:"form"`
+	Group    string `json:"group"`
+
+	filterOptions []product.FilterOption
+
 	sailOption *models.SailOption
 }
 
+// ComponentView is the view of a component listed by the command.
+// The Hosts and Endpoints are only available for the components of a zone.
+type ComponentView struct {
+	Name      string              `json:"name" yaml:"name"`
+	Version   string              `json:"version" yaml:"version"`
+	Form      string              `json:"form" yaml:"form"`
+	Enabled   bool                `json:"enabled" yaml:"enabled"`
+	External  bool                `json:"external" yaml:"external"`
+	Group     string              `json:"group" yaml:"group"`
+	Roles     []string            `json:"roles" yaml:"roles"`
+	Hosts     int                 `json:"hosts" yaml:"hosts"`
+	Endpoints map[string][]string `json:"endpoints" yaml:"endpoints"`
+}
+
 func NewListComponentsOptions(sailOption *models.SailOption) *ListComponentsOptions {
 	return &ListComponentsOptions{
 		sailOption: sailOption,
@@ -47,34 +85,162 @@ func NewListComponentsOptions(sailOption *models.SailOption) *ListComponentsOpti
 }
 
 func (o *ListComponentsOptions) Complete(cmd *cobra.Command, args []string) error {
-	if o.productName == "" {
-		return errors.New("product name must not be empty")
+	if o.productName != "" {
+		if o.TargetName != "" || o.ZoneName != "" {
+			return errors.New("can not specify product name together with target and zone names")
+		}
+
+		o.productDir = path.Join(o.sailOption.ProductsDir, o.productName)
+		stat, err := os.Stat(o.productDir)
+		if err != nil || !stat.IsDir() {
+			return fmt.Errorf("not found dir of product, %s does not exist", o.productDir)
+		}
+		return nil
 	}
 
-	o.productDir = path.Join(o.sailOption.ProductsDir, o.productName)
-	stat, err := os.Stat(o.productDir)
-	if err != nil || !stat.IsDir() {
-		return fmt.Errorf("not found dir of product, %s does not exist", o.productDir)
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+	if o.TargetName == "" || o.ZoneName == "" {
+		return errors.New("must specify product name, or target and zone names")
 	}
 
 	return nil
 }
 
 func (o *ListComponentsOptions) Validate() error {
+	if err := options.ValidateOutput(o.Output); err != nil {
+		return err
+	}
+
+	o.filterOptions = []product.FilterOption{}
+	if o.Enabled {
+		o.filterOptions = append(o.filterOptions, product.FilterOptionEnabled)
+	}
+	if o.External {
+		o.filterOptions = append(o.filterOptions, product.FilterOptionExternal)
+	}
+	if o.Form != "" {
+		f, err := product.NewFilterOptionByForm(o.Form)
+		if err != nil {
+			return err
+		}
+		o.filterOptions = append(o.filterOptions, f)
+	}
+	if o.Group != "" {
+		o.filterOptions = append(o.filterOptions, product.NewFilterOptionByGroup(o.Group))
+	}
+
 	return nil
 }
 
 func (o *ListComponentsOptions) Run() error {
-	product := product.NewProduct(o.productName, o.sailOption.ProductsDir)
-	if err := product.Init(); err != nil {
-		return fmt.Errorf("product init failed, err: %s", err)
+	if o.productName != "" {
+		p := product.NewProduct(o.productName, o.sailOption.ProductsDir)
+		if err := p.Init(); err != nil {
+			return fmt.Errorf("product init failed, err: %s", err)
+		}
+		out := target.NewMaskWriter(os.Stdout, nil)
+		defer out.Close()
+		return o.print(out, o.views(p, nil), false)
+	}
+
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	if err := zone.LoadConf(); err != nil {
+		return fmt.Errorf("zone.LoadConf failed, err: %s", err)
+	}
+	if err := zone.LoadInventorySources(); err != nil {
+		return err
+	}
+	if err := zone.Compute(); err != nil {
+		return fmt.Errorf("zone.Compute failed, err: %s", err)
 	}
 
-	components := product.ComponentList()
-	fmt.Printf("the product %s contains (%d) components:\n", o.productName, len(components))
-	for _, c := range components {
-		fmt.Printf("- %s\n", c)
+	// the endpoints may contain credentials
+	out := zone.MaskSecrets(os.Stdout)
+	defer out.Close()
+	return o.print(out, o.views(zone.Product, zone), true)
+}
+
+// views returns the views of the components of the product chosen by the filters.
+// The hosts and endpoints are filled only if zone is not nil.
+func (o *ListComponentsOptions) views(p *product.Product, zone *target.Zone) []*ComponentView {
+	out := []*ComponentView{}
+	for _, componentName := range p.ComponentListWithFilterOptionsAnd(o.filterOptions...) {
+		c := p.Components[componentName]
+
+		form := c.Form
+		if form == "" {
+			form = product.ComponentFormServer
+		}
+
+		view := &ComponentView{
+			Name:      componentName,
+			Version:   c.Version,
+			Form:      form,
+			Enabled:   c.Enabled,
+			External:  c.External,
+			Group:     c.Group,
+			Roles:     c.GetRoles(),
+			Endpoints: make(map[string][]string),
+		}
+
+		if zone != nil {
+			view.Hosts = len(zone.CMDB.GetHostsForComponent(componentName))
+			for svcName, computed := range c.Computed {
+				view.Endpoints[svcName] = computed.Endpoints
+			}
+		}
+
+		out = append(out, view)
 	}
 
+	return out
+}
+
+func (o *ListComponentsOptions) print(out io.Writer, views []*ComponentView, withZone bool) error {
+	if o.Output != options.OutputTable {
+		return options.FprintEncoded(out, o.Output, views)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if withZone {
+		fmt.Fprintln(w, "COMPONENT\tVERSION\tFORM\tENABLED\tEXTERNAL\tGROUP\tROLES\tHOSTS\tENDPOINTS")
+	} else {
+		fmt.Fprintln(w, "COMPONENT\tVERSION\tFORM\tENABLED\tEXTERNAL\tGROUP\tROLES")
+	}
+
+	for _, v := range views {
+		group := v.Group
+		if group == "" {
+			group = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%v\t%s\t%s", v.Name, v.Version, v.Form, v.Enabled, v.External, group, strings.Join(v.Roles, ","))
+		if withZone {
+			fmt.Fprintf(w, "\t%d\t%s", v.Hosts, strings.Join(endpoints(v), ","))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
 	return nil
 }
+
+// endpoints returns the endpoints of all services of the component, ordered by service names.
+func endpoints(v *ComponentView) []string {
+	svcNames := []string{}
+	for svcName := range v.Endpoints {
+		svcNames = append(svcNames, svcName)
+	}
+	sort.Strings(svcNames)
+
+	out := []string{}
+	for _, svcName := range svcNames {
+		out = append(out, v.Endpoints[svcName]...)
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package listtargets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdListTargets(sailOption *models.SailOption) *cobra.Command {
+	o := NewListTargetsOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "list-targets",
+		Short: "list the targets",
+		Long:  "list the targets with the products, helm modes, enabled components, hosts and last modification time of their zones",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Output, "output", "o", options.OutputTable, "output format, valid values: table, yaml, json")
+
+	return cmd
+}
+
+type ListTargetsOptions struct {
+	Output string `json:"output"`
+
+	sailOption *models.SailOption
+}
+
+func NewListTargetsOptions(sailOption *models.SailOption) *ListTargetsOptions {
+	return &ListTargetsOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *ListTargetsOptions) Complete(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *ListTargetsOptions) Validate() error {
+	return options.ValidateOutput(o.Output)
+}
+
+func (o *ListTargetsOptions) Run() error {
+	targetNames, err := target.AllTargets(o.sailOption)
+	if err != nil {
+		return fmt.Errorf("list targets failed, err: %s", err)
+	}
+
+	summaries := []*target.TargetSummary{}
+	for _, targetName := range targetNames {
+		s, err := target.NewTarget(o.sailOption, targetName).Summary()
+		if err != nil {
+			return fmt.Errorf("summary target (%s) failed, err: %s", targetName, err)
+		}
+		if s.Zones == 0 {
+			// not a target dir
+			continue
+		}
+		summaries = append(summaries, s)
+	}
+
+	if o.Output != options.OutputTable {
+		return options.PrintEncoded(o.Output, summaries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tZONES\tPRODUCTS\tHELM MODES\tENABLED COMPONENTS\tHOSTS\tMODIFIED")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%d\t%s\n",
+			s.Target, s.Zones, strings.Join(s.Products, ","), strings.Join(s.HelmModes, ","),
+			s.EnabledComponents, s.Hosts, options.FormatTime(s.ModifiedAt))
+	}
+	w.Flush()
+
+	for _, s := range summaries {
+		for _, e := range s.Errors {
+			fmt.Printf("warn: target (%s) zone %s\n", s.Target, e)
+		}
+		if len(s.InventorySourceZones) != 0 {
+			fmt.Printf("note: target (%s) zones (%s) declare inventory sources, the hosts from the sources are not counted\n",
+				s.Target, strings.Join(s.InventorySourceZones, ","))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package listzones
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdListZones(sailOption *models.SailOption) *cobra.Command {
+	o := NewListZonesOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "list-zones",
+		Short: "list the zones",
+		Long:  "list the zones with the product, helm mode, enabled components, hosts, last modification time and labels, list the zones of all targets if no target specified",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.Selector, "selector", "l", o.Selector, "only list the zones matching the label selector (eg: 'tier=canary')")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", options.OutputTable, "output format, valid values: table, yaml, json")
+
+	return cmd
+}
+
+type ListZonesOptions struct {
+	TargetName string `json:"target_name"`
+	Selector   string `json:"selector"`
+	Output     string `json:"output"`
+
+	sailOption *models.SailOption
+	selector   target.Selector
+}
+
+func NewListZonesOptions(sailOption *models.SailOption) *ListZonesOptions {
+	return &ListZonesOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *ListZonesOptions) Complete(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *ListZonesOptions) Validate() error {
+	selector, err := target.ParseSelector(o.Selector)
+	if err != nil {
+		return err
+	}
+	o.selector = selector
+
+	return options.ValidateOutput(o.Output)
+}
+
+func (o *ListZonesOptions) Run() error {
+	targetNames := []string{o.TargetName}
+	if o.TargetName == "" {
+		var err error
+		targetNames, err = target.AllTargets(o.sailOption)
+		if err != nil {
+			return fmt.Errorf("list targets failed, err: %s", err)
+		}
+	}
+
+	summaries := []*target.ZoneSummary{}
+	for _, targetName := range targetNames {
+		zoneNames, err := target.NewTarget(o.sailOption, targetName).AllZones()
+		if err != nil {
+			return fmt.Errorf("determine all zones for target (%s) failed, err: %s", targetName, err)
+		}
+
+		for _, zoneName := range zoneNames {
+			s := target.NewZone(o.sailOption, targetName, zoneName).Summary()
+			if !o.selector.Matches(s.Labels) {
+				continue
+			}
+			summaries = append(summaries, s)
+		}
+	}
+
+	if o.Output != options.OutputTable {
+		return options.PrintEncoded(o.Output, summaries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tZONE\tPRODUCT\tHELM MODE\tENABLED COMPONENTS\tHOSTS\tMODIFIED\tLABELS")
+	for _, s := range summaries {
+		if s.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\t%s\t%s\n", s.Target, s.Zone, s.Product, options.FormatTime(s.ModifiedAt), s.Labels)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			s.Target, s.Zone, s.Product, s.HelmMode, s.EnabledComponents, s.Hosts, options.FormatTime(s.ModifiedAt), s.Labels)
+	}
+	w.Flush()
+
+	for _, s := range summaries {
+		if s.Error != "" {
+			fmt.Printf("warn: zone (%s/%s) %s\n", s.Target, s.Zone, s.Error)
+		}
+		if s.InventorySources {
+			fmt.Printf("note: zone (%s/%s) declares inventory sources, the hosts from the sources are not counted\n", s.Target, s.Zone)
+		}
+	}
+
+	return nil
+}
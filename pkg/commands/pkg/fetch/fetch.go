@@ -0,0 +1,117 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/product"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdFetch(sailOption *models.SailOption) *cobra.Command {
+	o := NewFetchOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "download missing pkg files of components into packages dir",
+		Long:  "download missing pkg files of components into packages dir",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.ProductName, "product", "p", o.ProductName, "the product name, fetch for all components of the product")
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name, fetch for enabled components of the zone")
+	cmd.Flags().StringArrayVarP(&o.Components, "component", "c", o.Components, "the component")
+
+	return cmd
+}
+
+type FetchOptions struct {
+	ProductName string `json:"product_name"`
+	TargetName  string `json:"target_name"`
+	ZoneName    string `json:"zone_name"`
+
+	Components []string `json:"component"`
+
+	sailOption *models.SailOption
+}
+
+func NewFetchOptions(sailOption *models.SailOption) *FetchOptions {
+	return &FetchOptions{
+		Components: make([]string, 0),
+		sailOption: sailOption,
+	}
+}
+
+func (o *FetchOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.ProductName != "" {
+		return nil
+	}
+
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *FetchOptions) Validate() error {
+	if o.ProductName == "" && (o.TargetName == "" || o.ZoneName == "") {
+		return errors.New("must specify product name, or target name and zone name")
+	}
+	return nil
+}
+
+func (o *FetchOptions) Run() error {
+	components, err := options.ParseComponentsOption(o.Components)
+	if err != nil {
+		return fmt.Errorf("parse component option failed, err: %s", err)
+	}
+
+	var p *product.Product
+	componentNames := []string{}
+
+	if o.ProductName != "" {
+		p = product.NewProduct(o.ProductName, o.sailOption.ProductsDir)
+		if err := p.Init(); err != nil {
+			return fmt.Errorf("product init failed, err: %s", err)
+		}
+		componentNames = p.ComponentList()
+	} else {
+		options.PrintColorHeader(o.TargetName, o.ZoneName)
+
+		zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+		if err := zone.Load(); err != nil {
+			return err
+		}
+		p = zone.Product
+		componentNames = p.ComponentListWithFilterOptionsAnd(product.FilterOptionEnabled)
+	}
+
+	if len(components) != 0 {
+		for componentName := range components {
+			if !p.HasComponent(componentName) {
+				return fmt.Errorf("component (%s) is not a valid component name for product (%s)", componentName, p.Name)
+			}
+		}
+		componentNames = p.ComponentListWithFitlerOptionsOr(product.NewFilterOptionByComponentsMap(components))
+	}
+
+	if err := p.DownloadPkgs(o.sailOption.PackagesDir, os.Stdout, componentNames...); err != nil {
+		return fmt.Errorf("fetch pkgs failed, err: %s", err)
+	}
+
+	return nil
+}
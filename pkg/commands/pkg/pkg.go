@@ -0,0 +1,52 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/commands/pkg/fetch"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdPkg(sailOption *models.SailOption) *cobra.Command {
+	o := NewPkgOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "pkg",
+		Short: "manage the pkg files of components",
+		Long:  "manage the pkg files of components",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run(args))
+		},
+	}
+
+	cmd.AddCommand(fetch.NewCmdFetch(o.sailOption))
+
+	return cmd
+}
+
+type PkgOptions struct {
+	sailOption *models.SailOption
+}
+
+func NewPkgOptions(sailOption *models.SailOption) *PkgOptions {
+	return &PkgOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *PkgOptions) Complete(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *PkgOptions) Validate() error {
+	return nil
+}
+
+func (o *PkgOptions) Run(args []string) error {
+	fmt.Println("specify a concret command under pkg")
+	return nil
+}
@@ -0,0 +1,89 @@
+package rollback
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdRollback(sailOption *models.SailOption) *cobra.Command {
+	o := NewRollbackOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "restore the zone files from a configuration snapshot",
+		Long:  "restore the zone files from a configuration snapshot, use `sail history` to list the snapshots",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+	cmd.Flags().StringVarP(&o.To, "to", "", o.To, "the id of the snapshot to restore")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+type RollbackOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+	To         string `json:"to"`
+
+	sailOption *models.SailOption
+}
+
+func NewRollbackOptions(sailOption *models.SailOption) *RollbackOptions {
+	return &RollbackOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *RollbackOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *RollbackOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	if o.To == "" {
+		return errors.New("must specify the snapshot id by --to")
+	}
+	return nil
+}
+
+func (o *RollbackOptions) Run() error {
+	options.PrintColorHeader(o.TargetName, o.ZoneName)
+
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	current, err := zone.Rollback(o.To)
+	if err != nil {
+		return fmt.Errorf("rollback failed, err: %s", err)
+	}
+
+	fmt.Printf("restored zone files from snapshot (%s)\n", o.To)
+	if current != nil {
+		fmt.Printf("the replaced zone files are saved as snapshot (%s)\n", current.ID)
+	}
+
+	return nil
+}
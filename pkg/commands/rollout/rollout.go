@@ -0,0 +1,277 @@
+package rollout
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/commands/upgrade"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdRollout(sailOption *models.SailOption) *cobra.Command {
+	o := NewRolloutOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "rollout",
+		Short: "upgrade the components for the zones of the target wave by wave",
+		Long:  "upgrade the components for the zones of the target wave by wave, the waves are defined in the rollout.yaml file of the target",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run(args))
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringArrayVarP(&o.Components, "component", "c", o.Components, "the component")
+	cmd.Flags().IntVarP(&o.Parallel, "parallel", "", 1, "the max number of zones of a wave run at the same time")
+	cmd.Flags().BoolVarP(&o.NoFetch, "no-fetch", "", o.NoFetch, "do not download missing pkg files of components before running")
+	cmd.Flags().BoolVarP(&o.Restart, "restart", "", o.Restart, "start from the first wave, ignore the recorded progress of the previous rollout")
+	cmd.Flags().BoolVarP(&o.Yes, "yes", "y", o.Yes, "do not ask for confirmation between waves")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", o.DryRun, "print the waves and their zones, without running anything")
+
+	return cmd
+}
+
+type RolloutOptions struct {
+	TargetName string   `json:"target_name"`
+	Components []string `json:"component"`
+	Parallel   int      `json:"parallel"`
+
+	NoFetch bool `json:"no_fetch"`
+	Restart bool `json:"restart"`
+	Yes     bool `json:"yes"`
+	DryRun  bool `json:"dry_run"`
+
+	sailOption *models.SailOption
+}
+
+func NewRolloutOptions(sailOption *models.SailOption) *RolloutOptions {
+	return &RolloutOptions{
+		Components: make([]string, 0),
+		sailOption: sailOption,
+	}
+}
+
+func (o *RolloutOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	return nil
+}
+
+func (o *RolloutOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if len(o.Components) == 0 {
+		return errors.New("must specify at least one component to rollout")
+	}
+	if err := options.ValidateParallel(o.Parallel); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *RolloutOptions) Run(args []string) error {
+	t := target.NewTarget(o.sailOption, o.TargetName)
+	rollout, err := t.LoadRollout()
+	if err != nil {
+		return err
+	}
+
+	plan, rest, err := rollout.Plan(t)
+	if err != nil {
+		return fmt.Errorf("plan rollout failed, err: %s", err)
+	}
+	if len(rest) != 0 {
+		fmt.Printf("warn: zones not in any wave are not upgraded: %s\n", strings.Join(rest, ", "))
+	}
+
+	progress, err := t.LoadRolloutProgress()
+	if err != nil {
+		return err
+	}
+	if o.Restart || progress == nil || progress.Completed() || !progress.SameComponents(o.Components) {
+		progress = target.NewRolloutProgress(o.Components, rollout.Waves, plan)
+	} else {
+		if err := progress.CheckPlan(rollout.Waves, plan); err != nil {
+			return fmt.Errorf("%s\nrun with --restart to plan the rollout again", err)
+		}
+		fmt.Printf("resume the rollout of (%s) started at %s\n", strings.Join(o.Components, ", "), progress.StartedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	printPlan(rollout, plan, progress)
+	if o.DryRun {
+		return nil
+	}
+
+	u := upgrade.NewUpgradeOptions(o.sailOption)
+	u.TargetName = o.TargetName
+	u.Components = o.Components
+	u.NoFetch = o.NoFetch
+
+	for i, wave := range rollout.Waves {
+		zoneNames := plan[i]
+		last := i == len(rollout.Waves)-1
+
+		wp := progress.Wave(wave.Name)
+		if wp == nil {
+			wp = &target.WaveProgress{Name: wave.Name, Status: target.WaveStatusPending}
+			progress.Waves = append(progress.Waves, wp)
+		}
+
+		switch wp.Status {
+		case target.WaveStatusSucceeded:
+			fmt.Printf("wave (%s) already succeeded, skip\n", wave.Name)
+		case target.WaveStatusAwaitingConfirmation:
+			fmt.Printf("wave (%s) already succeeded, awaiting confirmation\n", wave.Name)
+		default:
+			wp.Zones = zoneNames
+			if len(zoneNames) == 0 {
+				fmt.Printf("wave (%s) has no zones, skip\n", wave.Name)
+			} else {
+				fmt.Printf("\n>>> wave (%s): %s\n", wave.Name, strings.Join(zoneNames, ", "))
+				if err := o.runWave(t, wave, zoneNames, u, args); err != nil {
+					wp.Status = target.WaveStatusFailed
+					wp.Error = err.Error()
+					wp.EndedAt = time.Now()
+					if err := t.SaveRolloutProgress(progress); err != nil {
+						return err
+					}
+					return fmt.Errorf("wave (%s) failed, the rollout is stopped, run the same command to resume from the wave\n%s", wave.Name, err)
+				}
+			}
+
+			// the wave is not done until it is confirmed, so that the resumed rollout asks again
+			wp.Status = target.WaveStatusSucceeded
+			if wave.Confirm && !o.Yes && !last {
+				wp.Status = target.WaveStatusAwaitingConfirmation
+			}
+			wp.Error = ""
+			wp.EndedAt = time.Now()
+			if err := t.SaveRolloutProgress(progress); err != nil {
+				return err
+			}
+		}
+
+		if last {
+			break
+		}
+		// the next wave already started, the pause and confirmation are done
+		if next := progress.Wave(rollout.Waves[i+1].Name); next != nil && next.Status != target.WaveStatusPending {
+			continue
+		}
+		if err := o.pause(t, progress, wave, wp, rollout.Waves[i+1]); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\nrollout of (%s) for target (%s) completed\n", strings.Join(o.Components, ", "), o.TargetName)
+	return nil
+}
+
+// runWave upgrades the zones of the wave, and then checks the gates for each zone.
+func (o *RolloutOptions) runWave(t *target.Target, wave *target.Wave, zoneNames []string, u *upgrade.UpgradeOptions, args []string) error {
+	results := options.RunZones(zoneNames, options.OnErrorStop, o.Parallel, func(zoneName string, zoneIO *options.ZoneIO) error {
+		return u.RunZone(o.TargetName, zoneName, args, zoneIO)
+	})
+	if err := options.PrintZoneResults(o.TargetName, results); err != nil {
+		return err
+	}
+
+	for _, zoneName := range zoneNames {
+		if len(wave.Gates) == 0 {
+			break
+		}
+
+		zone := target.NewZone(o.sailOption, o.TargetName, zoneName)
+		if err := zone.LoadConf(); err != nil {
+			return fmt.Errorf("load zone (%s) failed, err: %s", zoneName, err)
+		}
+		if err := zone.Compute(); err != nil {
+			return fmt.Errorf("compute zone (%s) failed, err: %s", zoneName, err)
+		}
+
+		for _, gate := range wave.Gates {
+			fmt.Printf("check %s gate for component (%s) of zone (%s)\n", gate.Type, gate.Component, zoneName)
+			if err := gate.Check(zone); err != nil {
+				return fmt.Errorf("zone (%s): %s", zoneName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// pause waits the rest of the pause duration since the wave ended, and asks for
+// confirmation if the wave is awaiting confirmation.
+func (o *RolloutOptions) pause(t *target.Target, progress *target.RolloutProgress, wave *target.Wave, wp *target.WaveProgress, next *target.Wave) error {
+	if d := time.Until(wp.EndedAt.Add(wave.PauseDuration())); d > 0 {
+		fmt.Printf("wave (%s) succeeded, pause %s before wave (%s)\n", wave.Name, d.Round(time.Second), next.Name)
+		time.Sleep(d)
+	}
+
+	if wp.Status != target.WaveStatusAwaitingConfirmation {
+		return nil
+	}
+
+	if !o.Yes {
+		if !isTerminal(os.Stdin) {
+			return fmt.Errorf("wave (%s) requires confirmation before wave (%s), run the same command in a terminal or with --yes to resume", wave.Name, next.Name)
+		}
+
+		fmt.Printf("wave (%s) succeeded, continue to wave (%s)? [y/N]: ", wave.Name, next.Name)
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("read answer failed, err: %s, run the same command with --yes to resume", err)
+		}
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return fmt.Errorf("the rollout is stopped before wave (%s), run the same command to resume", next.Name)
+		}
+	}
+
+	wp.Status = target.WaveStatusSucceeded
+	return t.SaveRolloutProgress(progress)
+}
+
+func printPlan(rollout *target.Rollout, plan [][]string, progress *target.RolloutProgress) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WAVE\tZONES\tSTATUS\tGATES\tPAUSE")
+	for i, wave := range rollout.Waves {
+		status := target.WaveStatusPending
+		if wp := progress.Wave(wave.Name); wp != nil {
+			status = wp.Status
+		}
+
+		gates := []string{}
+		for _, gate := range wave.Gates {
+			gates = append(gates, gate.Type+":"+gate.Component)
+		}
+
+		pause := wave.Pause
+		if wave.Confirm {
+			pause = strings.TrimPrefix(pause+",confirm", ",")
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", wave.Name, strings.Join(plan[i], ","), status, strings.Join(gates, ","), pause)
+	}
+	w.Flush()
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
@@ -8,13 +8,30 @@ import (
 	"strings"
 
 	"github.com/bougou/sail/pkg/commands/apply"
+	"github.com/bougou/sail/pkg/commands/bundle"
+	"github.com/bougou/sail/pkg/commands/check"
 	"github.com/bougou/sail/pkg/commands/confcreate"
+	"github.com/bougou/sail/pkg/commands/confmigrate"
 	"github.com/bougou/sail/pkg/commands/confupdate"
+	"github.com/bougou/sail/pkg/commands/describe"
 	"github.com/bougou/sail/pkg/commands/gensail"
+	"github.com/bougou/sail/pkg/commands/history"
+	"github.com/bougou/sail/pkg/commands/hosts"
 	"github.com/bougou/sail/pkg/commands/listcomponents"
+	"github.com/bougou/sail/pkg/commands/listtargets"
+	"github.com/bougou/sail/pkg/commands/listzones"
+	"github.com/bougou/sail/pkg/commands/pkg"
+	"github.com/bougou/sail/pkg/commands/rollback"
+	"github.com/bougou/sail/pkg/commands/rollout"
+	"github.com/bougou/sail/pkg/commands/scaledown"
+	"github.com/bougou/sail/pkg/commands/scaleup"
+	"github.com/bougou/sail/pkg/commands/secret"
+	"github.com/bougou/sail/pkg/commands/status"
+	"github.com/bougou/sail/pkg/commands/unbundle"
 	"github.com/bougou/sail/pkg/commands/upgrade"
 	"github.com/bougou/sail/pkg/commands/x"
 	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
 	"github.com/bougou/sail/pkg/version"
 	"github.com/mitchellh/go-homedir"
 
@@ -72,6 +89,12 @@ func NewSailCommand() *cobra.Command {
 	rootCmd.PersistentFlags().StringVarP(&sailOption.TargetsDir, "targets-dir", "", defaultTargetsDir, "the targets dir")
 	rootCmd.PersistentFlags().StringVarP(&sailOption.ProductsDir, "products-dir", "", defaultProductsDir, "the products dir")
 	rootCmd.PersistentFlags().StringVarP(&sailOption.PackagesDir, "packages-dir", "", defaultPackagesDir, "the packages dir")
+	rootCmd.PersistentFlags().StringVarP(&sailOption.LogDir, "log-dir", "", "", "the dir to store the log files of runs, default <zoneDir>/logs")
+	rootCmd.PersistentFlags().IntVarP(&sailOption.LogRetention, "log-retention", "", target.DefaultLogRetention, "the number of log files kept for each zone, 0 means keeping all")
+	rootCmd.PersistentFlags().IntVarP(&sailOption.HistoryRetention, "history-retention", "", target.DefaultHistoryRetention, "the number of snapshots kept for each zone, 0 means keeping all")
+	rootCmd.PersistentFlags().StringVarP(&sailOption.SecretKeyFile, "secret-key-file", "", "", "the file holding the key (32 random bytes in base64 or hex) to encrypt and decrypt the secrets of zones, the "+target.SecretKeyEnv+" env var takes precedence over it")
+	rootCmd.PersistentFlags().StringVarP(&sailOption.VaultPasswordFile, "vault-password-file", "", "", "the vault password file passed to ansible-playbook and ansible-vault, for the zone files or values encrypted by ansible-vault")
+	rootCmd.PersistentFlags().StringArrayVarP(&sailOption.VaultIDs, "vault-id", "", nil, "the vault identity (eg: 'prod@~/.vault_pass') passed to ansible-playbook and ansible-vault, can be specified multiple times")
 
 	rootCmd.PersistentFlags().StringVarP(&sailOption.DefaultTarget, "default-target", "", "", "the default target")
 	rootCmd.PersistentFlags().StringVarP(&sailOption.DefaultZone, "default-zone", "", "", "the default zone")
@@ -79,10 +102,26 @@ func NewSailCommand() *cobra.Command {
 	rootCmd.Flags().AddGoFlagSet(flag.CommandLine)
 
 	rootCmd.AddCommand(apply.NewCmdApply(sailOption))
+	rootCmd.AddCommand(bundle.NewCmdBundle(sailOption))
+	rootCmd.AddCommand(check.NewCmdCheck(sailOption))
 	rootCmd.AddCommand(confcreate.NewCmdConfCreate(sailOption))
+	rootCmd.AddCommand(confmigrate.NewCmdConfMigrate(sailOption))
 	rootCmd.AddCommand(confupdate.NewCmdConfUpdate(sailOption))
+	rootCmd.AddCommand(describe.NewCmdDescribe(sailOption))
 	rootCmd.AddCommand(gensail.NewCmdGenSail(sailOption))
+	rootCmd.AddCommand(history.NewCmdHistory(sailOption))
+	rootCmd.AddCommand(hosts.NewCmdHosts(sailOption))
 	rootCmd.AddCommand(listcomponents.NewCmdListComponents(sailOption))
+	rootCmd.AddCommand(listtargets.NewCmdListTargets(sailOption))
+	rootCmd.AddCommand(listzones.NewCmdListZones(sailOption))
+	rootCmd.AddCommand(pkg.NewCmdPkg(sailOption))
+	rootCmd.AddCommand(rollback.NewCmdRollback(sailOption))
+	rootCmd.AddCommand(rollout.NewCmdRollout(sailOption))
+	rootCmd.AddCommand(scaledown.NewCmdScaleDown(sailOption))
+	rootCmd.AddCommand(scaleup.NewCmdScaleUp(sailOption))
+	rootCmd.AddCommand(secret.NewCmdSecret(sailOption))
+	rootCmd.AddCommand(status.NewCmdStatus(sailOption))
+	rootCmd.AddCommand(unbundle.NewCmdUnbundle(sailOption))
 	rootCmd.AddCommand(upgrade.NewCmdUpgrade(sailOption))
 	rootCmd.AddCommand(x.NewCmdX(sailOption))
 
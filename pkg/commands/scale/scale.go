@@ -0,0 +1,213 @@
+package scale
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/product"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// ActionUp adds the hosts to the component
+	ActionUp = "up"
+	// ActionDown removes the hosts from the component
+	ActionDown = "down"
+)
+
+// NewCmdScale returns the scale-up or scale-down command according to action.
+// The plays of the component tagged with `<tag>-<componentName>` are run,
+// and the hosts are passed to the plays by the `sail_<tag>_hosts` variable.
+func NewCmdScale(sailOption *models.SailOption, action string, tag string) *cobra.Command {
+	o := NewScaleOptions(sailOption, action, tag)
+
+	short := "add hosts for a component and reconfigure its dependencies"
+	hostsUsage := "the hosts to be added to the component"
+	if action == ActionDown {
+		short = "remove hosts from a component and reconfigure its dependencies"
+		hostsUsage = "the hosts to be removed from the component"
+	}
+
+	cmd := &cobra.Command{
+		Use:   "scale-" + action,
+		Short: short,
+		Long:  short,
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run(args))
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+	cmd.Flags().StringVarP(&o.Component, "component", "c", o.Component, "the component to scale "+action)
+	_ = cmd.MarkFlagRequired("component")
+	cmd.Flags().StringArrayVarP(&o.Hosts, "hosts", "", o.Hosts, hostsUsage)
+	_ = cmd.MarkFlagRequired("hosts")
+
+	return cmd
+}
+
+type ScaleOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+
+	Component string   `json:"component"`
+	Hosts     []string `json:"hosts"`
+
+	action string
+	tag    string
+
+	sailOption *models.SailOption
+}
+
+func NewScaleOptions(sailOption *models.SailOption, action string, tag string) *ScaleOptions {
+	return &ScaleOptions{
+		Hosts:      make([]string, 0),
+		action:     action,
+		tag:        tag,
+		sailOption: sailOption,
+	}
+}
+
+func (o *ScaleOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *ScaleOptions) Validate() error {
+	if o.action != ActionUp && o.action != ActionDown {
+		return fmt.Errorf("not supported scale action (%s)", o.action)
+	}
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	if o.Component == "" {
+		return fmt.Errorf("must specify the component to scale %s", o.action)
+	}
+	if len(o.Hosts) == 0 {
+		return errors.New("must specify at least one --hosts option")
+	}
+	return nil
+}
+
+func (o *ScaleOptions) Run(args []string) error {
+	zoneIO := options.StdZoneIO()
+	options.FprintColorHeader(zoneIO.Out, o.TargetName, o.ZoneName)
+
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	if err := zone.Load(); err != nil {
+		return err
+	}
+	if err := zone.LoadInventorySources(); err != nil {
+		return err
+	}
+
+	component, ok := zone.Product.Components[o.Component]
+	if !ok {
+		return fmt.Errorf("component (%s) is not a valid component name for product (%s)", o.Component, zone.Product.Name)
+	}
+	if !component.Enabled {
+		return fmt.Errorf("component (%s) is not enabled, can not scale %s", o.Component, o.action)
+	}
+	if component.Form == product.ComponentFormPod {
+		return fmt.Errorf("component (%s) is deployed as pod, scale %s by hosts is not supported", o.Component, o.action)
+	}
+
+	// make sure the inventory group of the component exists before patching hosts
+	if err := zone.Compute(); err != nil {
+		return fmt.Errorf("zone compute failed, err: %s", err)
+	}
+
+	hosts := []string{}
+	for _, h := range o.Hosts {
+		hosts = append(hosts, strings.Split(h, ",")...)
+	}
+
+	hostsAction := "+"
+	if o.action == ActionDown {
+		if err := o.checkRemovedHosts(zone, hosts); err != nil {
+			return err
+		}
+		hostsAction = "-"
+	}
+
+	m, err := options.ParseHostsOptions([]string{hostsAction + o.Component + "/" + strings.Join(hosts, ",")})
+	if err != nil {
+		return fmt.Errorf("parse hosts option failed, err: %s", err)
+	}
+	if err := zone.PatchActionHostsMap(m); err != nil {
+		return fmt.Errorf("patch hosts failed, err: %s", err)
+	}
+
+	if err := zone.Dump(); err != nil {
+		return fmt.Errorf("zone.Dump failed, err: %s", err)
+	}
+
+	// roles can use this variable to know which hosts are added or removed,
+	// the removed hosts are no longer in the inventory group of the component (eg: use them by delegate_to).
+	b, err := json.Marshal(map[string]interface{}{"sail_" + o.tag + "_hosts": hosts})
+	if err != nil {
+		return fmt.Errorf("marshal extra vars failed, err: %s", err)
+	}
+	scaleArgs := append([]string{"-e", string(b)}, args...)
+
+	rz := target.NewRunningZone(zone, zone.Product.DefaultPlaybook())
+	rz.WithServerComponents(map[string]string{o.Component: ""})
+	// Note: Ansible Tag for scale up or scale down component
+	rz.WithAnsiblePlaybookTags([]string{o.tag + "-" + o.Component})
+	rz.WithOperation("scale-" + o.action)
+	rz.WithIO(zoneIO.In, zoneIO.Out, zoneIO.ErrOut)
+	if err := rz.Run(scaleArgs); err != nil {
+		return fmt.Errorf("scale %s component (%s) failed, err: %s", o.action, o.Component, err)
+	}
+
+	return zone.ReconfigureDependencies(o.Component, args, zoneIO.In, zoneIO.Out, zoneIO.ErrOut)
+}
+
+// checkRemovedHosts checks the hosts to be removed belong to the component, and not all hosts are removed.
+func (o *ScaleOptions) checkRemovedHosts(zone *target.Zone, hosts []string) error {
+	currentHosts := zone.CMDB.GetHostsForComponent(o.Component)
+	currentHostsMap := make(map[string]bool)
+	for _, h := range currentHosts {
+		currentHostsMap[h] = true
+	}
+	for _, h := range hosts {
+		if !currentHostsMap[h] {
+			return fmt.Errorf("host (%s) does not belong to component (%s), current hosts: %v", h, o.Component, currentHosts)
+		}
+	}
+	if len(dedupHosts(hosts)) >= len(currentHosts) {
+		return fmt.Errorf("can not remove all hosts of component (%s), disable the component instead", o.Component)
+	}
+	return nil
+}
+
+func dedupHosts(hosts []string) []string {
+	keys := make(map[string]bool)
+	out := []string{}
+
+	for _, v := range hosts {
+		if _, exists := keys[v]; !exists {
+			keys[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
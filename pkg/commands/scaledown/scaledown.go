@@ -1,3 +1,11 @@
-package scaleup
+package scaledown
 
-// Todo
+import (
+	"github.com/bougou/sail/pkg/commands/scale"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdScaleDown(sailOption *models.SailOption) *cobra.Command {
+	return scale.NewCmdScale(sailOption, scale.ActionDown, "scaledown")
+}
@@ -0,0 +1,99 @@
+package get
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdGet(sailOption *models.SailOption) *cobra.Command {
+	o := NewGetOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "get [name]",
+		Short: "print the decrypted value of a secret of the zone",
+		Long:  "print the decrypted value of a secret of the zone, or list the names of all secrets if no name specified",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+
+	return cmd
+}
+
+type GetOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+
+	name string
+
+	sailOption *models.SailOption
+}
+
+func NewGetOptions(sailOption *models.SailOption) *GetOptions {
+	return &GetOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *GetOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+	if len(args) == 1 {
+		o.name = args[0]
+	}
+
+	return nil
+}
+
+func (o *GetOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	return nil
+}
+
+func (o *GetOptions) Run() error {
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+
+	if o.name == "" {
+		names, err := zone.SecretNames()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	key, err := target.SecretKey(o.sailOption.SecretKeyFile)
+	if err != nil {
+		return err
+	}
+
+	value, err := zone.GetSecret(key, o.name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(value)
+	return nil
+}
@@ -0,0 +1,115 @@
+package rotate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdRotate(sailOption *models.SailOption) *cobra.Command {
+	o := NewRotateOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "re-encrypt the secrets of zones with a new key",
+		Long:  "re-encrypt the secrets of zones with a new key, the new key is read from the " + target.NewSecretKeyEnv + " env var or the file specified by --new-key-file",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+	cmd.Flags().BoolVarP(&o.AllZones, "all-zones", "", o.AllZones, "choose all zones, no meaning if explicitly specified a zone")
+	cmd.Flags().StringVarP(&o.NewKeyFile, "new-key-file", "", o.NewKeyFile, "the file holding the new key")
+
+	return cmd
+}
+
+type RotateOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+	AllZones   bool   `json:"all_zones"`
+	NewKeyFile string `json:"new_key_file"`
+
+	sailOption *models.SailOption
+}
+
+func NewRotateOptions(sailOption *models.SailOption) *RotateOptions {
+	return &RotateOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *RotateOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" && !o.AllZones {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *RotateOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" && !o.AllZones {
+		return errors.New("must specify zone name, or choose all zones by specify '--all-zones' option")
+	}
+	return nil
+}
+
+func (o *RotateOptions) Run() error {
+	oldKey, err := target.SecretKey(o.sailOption.SecretKeyFile)
+	if err != nil {
+		return err
+	}
+	newKey, err := target.NewSecretKey(o.NewKeyFile)
+	if err != nil {
+		return err
+	}
+
+	zoneNames := []string{o.ZoneName}
+	if o.ZoneName == "" {
+		zoneNames, err = options.ChooseZones(o.sailOption, o.TargetName, nil, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	// decrypt the secrets of all zones before writing any of them,
+	// so a wrong old key does not leave the zones encrypted by different keys
+	zones := []*target.Zone{}
+	for _, zoneName := range zoneNames {
+		zone := target.NewZone(o.sailOption, o.TargetName, zoneName)
+		if !zone.HasSecrets() {
+			continue
+		}
+		if _, err := zone.LoadSecrets(oldKey); err != nil {
+			return fmt.Errorf("zone (%s): %s", zoneName, err)
+		}
+		zones = append(zones, zone)
+	}
+
+	for _, zone := range zones {
+		if err := zone.RotateSecrets(oldKey, newKey); err != nil {
+			return fmt.Errorf("rotate secrets for zone (%s) failed, err: %s", zone.ZoneName, err)
+		}
+		fmt.Printf("rotated secrets of zone (%s/%s)\n", o.TargetName, zone.ZoneName)
+	}
+
+	if len(zones) == 0 {
+		fmt.Println("no secrets found")
+	}
+	return nil
+}
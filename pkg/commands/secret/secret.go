@@ -0,0 +1,56 @@
+package secret
+
+import (
+	"fmt"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/commands/secret/get"
+	"github.com/bougou/sail/pkg/commands/secret/rotate"
+	"github.com/bougou/sail/pkg/commands/secret/set"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdSecret(sailOption *models.SailOption) *cobra.Command {
+	o := NewSecretOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "manage the encrypted secrets of zones",
+		Long:  "manage the encrypted secrets of zones, the secrets are stored in the secrets.enc.yaml file of the zone and passed to ansible-playbook and helm as variables",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run(args))
+		},
+	}
+
+	cmd.AddCommand(get.NewCmdGet(o.sailOption))
+	cmd.AddCommand(rotate.NewCmdRotate(o.sailOption))
+	cmd.AddCommand(set.NewCmdSet(o.sailOption))
+
+	return cmd
+}
+
+type SecretOptions struct {
+	sailOption *models.SailOption
+}
+
+func NewSecretOptions(sailOption *models.SailOption) *SecretOptions {
+	return &SecretOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *SecretOptions) Complete(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *SecretOptions) Validate() error {
+	return nil
+}
+
+func (o *SecretOptions) Run(args []string) error {
+	fmt.Println("specify a concret command under secret")
+	return nil
+}
@@ -0,0 +1,106 @@
+package set
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdSet(sailOption *models.SailOption) *cobra.Command {
+	o := NewSetOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "set <name> [value]",
+		Short: "encrypt and save a secret of the zone",
+		Long:  "encrypt and save a secret of the zone, the value is read from stdin if not specified, to keep it out of the shell history",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+
+	return cmd
+}
+
+type SetOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+
+	name  string
+	value string
+
+	sailOption *models.SailOption
+}
+
+func NewSetOptions(sailOption *models.SailOption) *SetOptions {
+	return &SetOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *SetOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	o.name = args[0]
+	if len(args) == 2 {
+		o.value = args[1]
+		return nil
+	}
+
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("read secret value from stdin failed, err: %s", err)
+	}
+	o.value = strings.TrimRight(string(b), "\r\n")
+
+	return nil
+}
+
+func (o *SetOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	if o.value == "" {
+		return errors.New("the secret value is empty")
+	}
+	return target.ValidateSecretName(o.name)
+}
+
+func (o *SetOptions) Run() error {
+	key, err := target.SecretKey(o.sailOption.SecretKeyFile)
+	if err != nil {
+		return err
+	}
+
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	if _, err := zone.ParseZoneMeta(); err != nil {
+		return err
+	}
+
+	if err := zone.SetSecret(key, o.name, o.value); err != nil {
+		return fmt.Errorf("set secret failed, err: %s", err)
+	}
+
+	fmt.Printf("secret (%s) saved to %s\n", o.name, zone.SecretsFile)
+	return nil
+}
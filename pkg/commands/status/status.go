@@ -0,0 +1,125 @@
+package status
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/product"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/bougou/sail/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdStatus(sailOption *models.SailOption) *cobra.Command {
+	o := NewStatusOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show the last deployed version and result of each component of the zone",
+		Long:  "show the last deployed version and result of each component of the zone, according to the run records of the zone",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
+	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
+
+	return cmd
+}
+
+type StatusOptions struct {
+	TargetName string `json:"target_name"`
+	ZoneName   string `json:"zone_name"`
+
+	sailOption *models.SailOption
+}
+
+func NewStatusOptions(sailOption *models.SailOption) *StatusOptions {
+	return &StatusOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *StatusOptions) Complete(cmd *cobra.Command, args []string) error {
+	if o.TargetName == "" {
+		o.TargetName = o.sailOption.DefaultTarget
+	}
+	if o.ZoneName == "" {
+		o.ZoneName = o.sailOption.DefaultZone
+	}
+
+	return nil
+}
+
+func (o *StatusOptions) Validate() error {
+	if o.TargetName == "" {
+		return errors.New("must specify target name")
+	}
+	if o.ZoneName == "" {
+		return errors.New("must specify zone name")
+	}
+	return nil
+}
+
+func (o *StatusOptions) Run() error {
+	options.PrintColorHeader(o.TargetName, o.ZoneName)
+
+	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
+	if err := zone.LoadConf(); err != nil {
+		return fmt.Errorf("zone.LoadConf failed, err: %s", err)
+	}
+
+	results, err := zone.LastComponentResults()
+	if err != nil {
+		return fmt.Errorf("load run records failed, err: %s", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tCONFIGURED\tDEPLOYED\tDEPLOYED AT\tEFFECTIVE\tLAST RESULT\tOPERATION\tRUN")
+	for _, componentName := range zone.Product.ComponentList() {
+		c := zone.Product.Components[componentName]
+		result, ok := results[componentName]
+		if !ok {
+			if c.Enabled {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t-\t-\n", componentName, c.Version)
+			}
+			continue
+		}
+
+		configured := c.Version
+		if !product.FilterOptionEnabled(c) {
+			configured = "(disabled)"
+		}
+
+		// the records written before the effective version is recorded
+		effective := result.EffectiveVersion
+		if effective == "" {
+			effective = "-"
+		}
+
+		// the deployed version is the version of the last successful run
+		deployed, deployedAt := "-", "-"
+		if result.LastSuccess != nil {
+			deployed = result.LastSuccess.Version
+			deployedAt = result.LastSuccess.EndedAt.Format("2006-01-02 15:04:05")
+		}
+
+		r := "succeeded"
+		if !result.Success {
+			r = fmt.Sprintf("failed (exit %d, %s)", result.ExitCode, result.Version)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			componentName, configured, deployed, deployedAt, effective, r, result.Operation, result.RunID)
+	}
+	w.Flush()
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package unbundle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/product"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdUnbundle(sailOption *models.SailOption) *cobra.Command {
+	o := NewUnbundleOptions(sailOption)
+
+	cmd := &cobra.Command{
+		Use:   "unbundle",
+		Short: "extract an offline archive into products dir and packages dir",
+		Long:  "extract an offline archive into products dir and packages dir",
+		Run: func(cmd *cobra.Command, args []string) {
+			common.CheckErr(o.Complete(cmd, args))
+			common.CheckErr(o.Validate())
+			common.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.file, "file", "f", "", "the bundle file")
+	_ = cmd.MarkFlagRequired("file")
+	cmd.Flags().BoolVarP(&o.force, "force", "", false, "overwrite the product dir if it already exists")
+
+	return cmd
+}
+
+type UnbundleOptions struct {
+	file  string
+	force bool
+
+	sailOption *models.SailOption
+}
+
+func NewUnbundleOptions(sailOption *models.SailOption) *UnbundleOptions {
+	return &UnbundleOptions{
+		sailOption: sailOption,
+	}
+}
+
+func (o *UnbundleOptions) Complete(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *UnbundleOptions) Validate() error {
+	if o.file == "" {
+		return errors.New("bundle file must not be empty")
+	}
+
+	if _, err := os.Stat(o.file); err != nil {
+		return fmt.Errorf("access bundle file failed, err: %s", err)
+	}
+
+	return nil
+}
+
+func (o *UnbundleOptions) Run() error {
+	manifest, err := product.Unbundle(o.file, o.sailOption.ProductsDir, o.sailOption.PackagesDir, o.force)
+	if err != nil {
+		return fmt.Errorf("unbundle failed, err: %s", err)
+	}
+
+	fmt.Printf("unbundled product %s (%d files, created at %s by sail %s)\n", manifest.Product, len(manifest.Files), manifest.CreatedAt, manifest.SailVersion)
+	fmt.Printf("products dir: %s\n", o.sailOption.ProductsDir)
+	fmt.Printf("packages dir: %s\n", o.sailOption.PackagesDir)
+
+	return nil
+}
@@ -28,21 +28,36 @@ func NewCmdUpgrade(sailOption *models.SailOption) *cobra.Command {
 	cmd.Flags().StringVarP(&o.TargetName, "target", "t", o.TargetName, "target name")
 	cmd.Flags().StringVarP(&o.ZoneName, "zone", "z", o.ZoneName, "zone name")
 	cmd.Flags().BoolVarP(&o.AllZones, "all-zones", "", o.AllZones, "choose all zones, no meaning if explicitly specified a zone")
+	cmd.Flags().StringArrayVarP(&o.Zones, "zones", "", o.Zones, "choose the zones matching the glob patterns (eg: 'prod-*'), no meaning if explicitly specified a zone")
+	cmd.Flags().StringVarP(&o.Selector, "selector", "l", o.Selector, "choose the zones matching the label selector (eg: 'tier=canary'), no meaning if explicitly specified a zone")
+	cmd.Flags().StringVarP(&o.OnError, "on-error", "", options.OnErrorContinue, "what to do when a zone failed with multiple zones, valid values: continue, stop")
+	cmd.Flags().IntVarP(&o.Parallel, "parallel", "", 1, "the max number of zones run at the same time with multiple zones")
 	cmd.Flags().StringArrayVarP(&o.Components, "component", "c", o.Components, "the component")
 	cmd.Flags().BoolVarP(&o.Ansible, "ansible", "", o.Ansible, "choose components deployed as server")
 	cmd.Flags().BoolVarP(&o.Helm, "helm", "", o.Helm, "choose components deployed as pod")
+	cmd.Flags().BoolVarP(&o.NoFetch, "no-fetch", "", o.NoFetch, "do not download missing pkg files of components before running")
+	cmd.Flags().BoolVarP(&o.NoPersist, "no-persist", "", o.NoPersist, "only use the component versions specified by --component for this run, never write them to the zone vars file")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", o.DryRun, "print the diff of zone files and the commands to be executed, without changing anything")
 	return cmd
 }
 
 type UpgradeOptions struct {
-	TargetName string `json:"target_name"`
-	ZoneName   string `json:"zone_name"`
-	AllZones   bool   `json:"all_zones"`
+	TargetName string   `json:"target_name"`
+	ZoneName   string   `json:"zone_name"`
+	AllZones   bool     `json:"all_zones"`
+	Zones      []string `json:"zones"`
+	Selector   string   `json:"selector"`
+	OnError    string   `json:"on_error"`
+	Parallel   int      `json:"parallel"`
 
 	Components []string `json:"component"`
 	Ansible    bool     `json:"ansible"`
 	Helm       bool     `json:"helm"`
 
+	NoFetch   bool `json:"no_fetch"`
+	NoPersist bool `json:"no_persist"`
+	DryRun    bool `json:"dry_run"`
+
 	sailOption *models.SailOption
 }
 
@@ -57,7 +72,8 @@ func (o *UpgradeOptions) Complete(cmd *cobra.Command, args []string) error {
 	if o.TargetName == "" {
 		o.TargetName = o.sailOption.DefaultTarget
 	}
-	if o.ZoneName == "" {
+	// the default zone is not used when choosing multiple zones
+	if o.ZoneName == "" && !o.AllZones && len(o.Zones) == 0 && o.Selector == "" {
 		o.ZoneName = o.sailOption.DefaultZone
 	}
 
@@ -68,40 +84,40 @@ func (o *UpgradeOptions) Validate() error {
 	if o.TargetName == "" {
 		return errors.New("must specify target name")
 	}
-	if o.ZoneName == "" && !o.AllZones {
-		return errors.New("must specify zone name, or choose all zones by sepcify '--all-zones' option")
+	if o.ZoneName == "" && !o.AllZones && len(o.Zones) == 0 && o.Selector == "" {
+		return errors.New("must specify zone name, or choose zones by specify '--zones', '--selector' or '--all-zones' option")
+	}
+	if err := options.ValidateOnError(o.OnError); err != nil {
+		return err
+	}
+	if err := options.ValidateParallel(o.Parallel); err != nil {
+		return err
 	}
 	return nil
 }
 
 func (o *UpgradeOptions) Run(args []string) error {
 	if o.ZoneName != "" {
-		return o.run(o.TargetName, o.ZoneName, args)
+		return o.RunZone(o.TargetName, o.ZoneName, args, options.StdZoneIO())
 	}
 
-	if o.AllZones {
-		t := target.NewTarget(o.sailOption, o.TargetName)
-		zoneNames, err := t.AllZones()
-		if err != nil {
-			return fmt.Errorf("determine all zones for target (%s) failed, err: %s", o.TargetName, err)
-		}
-
-		for _, zoneName := range zoneNames {
-			if err := o.run(o.TargetName, zoneName, args); err != nil {
-				// todo, add options for error handling
-				continue
-			}
-		}
+	zoneNames, err := options.ChooseZones(o.sailOption, o.TargetName, o.Zones, o.Selector)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	results := options.RunZones(zoneNames, o.OnError, o.Parallel, func(zoneName string, zoneIO *options.ZoneIO) error {
+		return o.RunZone(o.TargetName, zoneName, args, zoneIO)
+	})
+	return options.PrintZoneResults(o.TargetName, results)
 }
 
-func (o *UpgradeOptions) run(targetName string, zoneName string, args []string) error {
-	options.PrintColorHeader(o.TargetName, o.ZoneName)
+// RunZone upgrades the chosen components for the zone.
+func (o *UpgradeOptions) RunZone(targetName string, zoneName string, args []string, zoneIO *options.ZoneIO) error {
+	options.FprintColorHeader(zoneIO.Out, targetName, zoneName)
 
-	zone := target.NewZone(o.sailOption, o.TargetName, o.ZoneName)
-	if err := zone.Load(); err != nil {
+	zone := target.NewZone(o.sailOption, targetName, zoneName)
+	if err := o.load(zone); err != nil {
 		return err
 	}
 
@@ -110,8 +126,20 @@ func (o *UpgradeOptions) run(targetName string, zoneName string, args []string)
 		return fmt.Errorf("parse component option failed, err: %s", err)
 	}
 
-	if err := zone.Dump(); err != nil {
-		return fmt.Errorf("zone.Dump failed, err: %s", err)
+	if o.DryRun {
+		if err := options.PrintZonePlan(zoneIO.Out, zone); err != nil {
+			return err
+		}
+	} else {
+		if !o.NoFetch {
+			if err := zone.FetchPkgs(zoneIO.Out, serverComponents, podComponents); err != nil {
+				return fmt.Errorf("fetch pkgs failed, err: %s", err)
+			}
+		}
+
+		if err := zone.Dump(); err != nil {
+			return fmt.Errorf("zone.Dump failed, err: %s", err)
+		}
 	}
 
 	var ansiblePlaybookTags []string
@@ -125,6 +153,22 @@ func (o *UpgradeOptions) run(targetName string, zoneName string, args []string)
 	rz.WithServerComponents(serverComponents)
 	rz.WithPodComponents(podComponents)
 	rz.WithAnsiblePlaybookTags(ansiblePlaybookTags)
+	rz.WithDryRun(o.DryRun)
+	rz.WithNoPersist(o.NoPersist)
+	rz.WithIO(zoneIO.In, zoneIO.Out, zoneIO.ErrOut)
+	rz.WithOperation("upgrade")
 
 	return rz.Run(args)
 }
+
+// load loads the zone and its inventory sources. When dry-run, the helm charts are not prepared to avoid writing files.
+func (o *UpgradeOptions) load(zone *target.Zone) error {
+	load := zone.Load
+	if o.DryRun {
+		load = zone.LoadConf
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	return zone.LoadInventorySources()
+}
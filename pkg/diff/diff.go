@@ -0,0 +1,288 @@
+// Package diff implements a line based unified diff.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around the changes.
+const DefaultContext = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns the unified diff of a and b, named by aName and bName in the header.
+// It returns empty string if a and b are same.
+func Unified(aName string, bName string, a string, b string) string {
+	if a == b {
+		return ""
+	}
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "--- %s\n", aName)
+	fmt.Fprintf(sb, "+++ %s\n", bName)
+	for _, h := range hunks(ops, DefaultContext) {
+		writeHunk(sb, ops, h)
+	}
+
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script which converts a to b.
+// It uses the linear space variant of the Myers' O(ND) algorithm, which splits the
+// problem by the middle snake (the middle part of the shortest edit path) recursively,
+// so the memory is O(n+m) instead of O(n*m) of the longest common subsequence table.
+func diffLines(a []string, b []string) []op {
+	return appendDiff(make([]op, 0, len(a)+len(b)), a, b)
+}
+
+func appendDiff(ops []op, a []string, b []string) []op {
+	// the common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		ops = append(ops, op{opEqual, line})
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	switch {
+	case len(ma) == 0:
+		for _, line := range mb {
+			ops = append(ops, op{opInsert, line})
+		}
+	case len(mb) == 0:
+		for _, line := range ma {
+			ops = append(ops, op{opDelete, line})
+		}
+	default:
+		if x, y, ok := middleSnake(ma, mb); ok {
+			ops = appendDiff(ops, ma[:x], mb[:y])
+			ops = appendDiff(ops, ma[x:], mb[y:])
+		} else {
+			for _, line := range ma {
+				ops = append(ops, op{opDelete, line})
+			}
+			for _, line := range mb {
+				ops = append(ops, op{opInsert, line})
+			}
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, op{opEqual, line})
+	}
+
+	return ops
+}
+
+// middleSnake searches the shortest edit path of a and b from both ends at the same time,
+// and returns the point (x, y) where the two searches meet, which splits a and b into two
+// smaller problems. a and b must be non-empty, and ok is false if they have no common lines.
+func middleSnake(a []string, b []string) (x int, y int, ok bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+
+	// vf[offset+k] is the furthest x reached on diagonal k (k = x - y) from the start,
+	// vb[offset+k] is the furthest x reached on diagonal k from the end, in reversed coordinates.
+	vf := make([]int, 2*offset+1)
+	vb := make([]int, 2*offset+1)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	delta := n - m
+	// when delta is odd, the forward search meets the backward search after it moves,
+	// otherwise the backward search meets the forward search after it moves.
+	front := delta%2 != 0
+
+	// the diagonals out of the edit graph are skipped
+	kfStart, kfEnd, kbStart, kbEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + kfStart; k <= d-kfEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			switch {
+			case x > n:
+				kfEnd += 2
+			case y > m:
+				kfStart += 2
+			case front:
+				kb := delta - k
+				if kb >= -maxD && kb <= maxD && vb[offset+kb] != -1 && x >= n-vb[offset+kb] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + kbStart; k <= d-kbEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[offset+k-1] < vb[offset+k+1]) {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+
+			switch {
+			case x > n:
+				kbEnd += 2
+			case y > m:
+				kbStart += 2
+			case !front:
+				kf := delta - k
+				if kf >= -maxD && kf <= maxD && vf[offset+kf] != -1 && vf[offset+kf] >= n-x {
+					return vf[offset+kf], vf[offset+kf] - kf, true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// hunk is the range [start, end) of the ops.
+type hunk struct {
+	start int
+	end   int
+}
+
+// hunks groups the changed ops with the surrounding context lines.
+func hunks(ops []op, context int) []hunk {
+	out := []hunk{}
+
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == opEqual {
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// extend the hunk until there are more than 2*context equal lines after the last change
+		end := i + 1
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			k := end
+			for k < len(ops) && ops[k].kind == opEqual {
+				k++
+			}
+			if k == len(ops) || k-end > 2*context {
+				break
+			}
+			end = k
+		}
+		last := end
+		end += context
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		if len(out) > 0 && start <= out[len(out)-1].end {
+			out[len(out)-1].end = end
+		} else {
+			out = append(out, hunk{start, end})
+		}
+		i = last - 1
+	}
+
+	return out
+}
+
+func writeHunk(sb *strings.Builder, ops []op, h hunk) {
+	// line numbers (1-based) of the hunk start in a and b
+	aLine, bLine := 1, 1
+	for _, o := range ops[:h.start] {
+		if o.kind != opInsert {
+			aLine++
+		}
+		if o.kind != opDelete {
+			bLine++
+		}
+	}
+
+	aCount, bCount := 0, 0
+	for _, o := range ops[h.start:h.end] {
+		if o.kind != opInsert {
+			aCount++
+		}
+		if o.kind != opDelete {
+			bCount++
+		}
+	}
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+	for _, o := range ops[h.start:h.end] {
+		prefix := " "
+		switch o.kind {
+		case opDelete:
+			prefix = "-"
+		case opInsert:
+			prefix = "+"
+		}
+		line := o.line
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n\\ No newline at end of file\n"
+		}
+		sb.WriteString(prefix + line)
+	}
+}
@@ -0,0 +1,122 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+
+	expected := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+`
+	if got := Unified("old", "new", a, b); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if got := Unified("old", "new", a, a); got != "" {
+		t.Errorf("expected empty diff for same content, got:\n%s", got)
+	}
+
+	expected = `--- old
++++ new
+@@ -0,0 +1,2 @@
++x
++y
+`
+	if got := Unified("old", "new", "", "x\ny\n"); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rnd.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+
+		// the ops must convert a to b with the minimal edits
+		gotA, gotB, equal := []string{}, []string{}, 0
+		for _, o := range ops {
+			if o.kind != opInsert {
+				gotA = append(gotA, o.line)
+			}
+			if o.kind != opDelete {
+				gotB = append(gotB, o.line)
+			}
+			if o.kind == opEqual {
+				equal++
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("the ops do not convert %v to %v: %v", a, b, ops)
+		}
+		if l := lcsLength(a, b); equal != l {
+			t.Fatalf("expected %d equal lines for %v and %v, got %d", l, a, b, equal)
+		}
+	}
+}
+
+func TestUnified_Large(t *testing.T) {
+	a, b := &strings.Builder{}, &strings.Builder{}
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(a, "line %d\n", i)
+		if i%10000 == 0 {
+			fmt.Fprintf(b, "changed line %d\n", i)
+			continue
+		}
+		fmt.Fprintf(b, "line %d\n", i)
+	}
+
+	got := Unified("old", "new", a.String(), b.String())
+	if n := strings.Count(got, "\n-line "); n != 10 {
+		t.Errorf("expected 10 deleted lines, got %d", n)
+	}
+	if n := strings.Count(got, "\n+changed line "); n != 10 {
+		t.Errorf("expected 10 inserted lines, got %d", n)
+	}
+}
+
+func lcsLength(a []string, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return lcs[0][0]
+}
@@ -0,0 +1,502 @@
+package product
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bougou/sail/pkg/version"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	BundleManifestFile = "manifest.yaml"
+
+	// the top level dirs in the bundle archive
+	BundleProductsDir = "products"
+	BundlePackagesDir = "packages"
+
+	SharedRolesDir = "shared_roles"
+)
+
+// BundleManifest describes the content of a bundle archive.
+// It is always the first entry of the archive.
+type BundleManifest struct {
+	Product     string       `yaml:"product"`
+	SailVersion string       `yaml:"sailVersion"`
+	CreatedAt   string       `yaml:"createdAt"`
+	Files       []BundleFile `yaml:"files"`
+}
+
+type BundleFile struct {
+	// Path is the slash separated path of the file in the archive,
+	// it always starts with BundleProductsDir or BundlePackagesDir.
+	Path   string `yaml:"path"`
+	Size   int64  `yaml:"size"`
+	SHA256 string `yaml:"sha256"`
+
+	src  string
+	mode fs.FileMode
+}
+
+// PkgFiles returns the pkg files declared by all components of the product.
+// The returned files are relative to the packages dir.
+func (p *Product) PkgFiles() []string {
+	out := []string{}
+	for _, componentName := range p.ComponentList() {
+		for _, pkg := range p.Components[componentName].Pkgs {
+			if pkg.File == nil || *pkg.File == "" {
+				continue
+			}
+			out = append(out, *pkg.File)
+		}
+	}
+
+	return dedupSliceString(out)
+}
+
+// Bundle packs the product operation code, the shared roles and the pkg files
+// declared by the components into the tar.gz file dstFile.
+func (p *Product) Bundle(packagesDir string, dstFile string) (*BundleManifest, error) {
+	manifest := &BundleManifest{
+		Product:     p.Name,
+		SailVersion: version.Version,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		Files:       make([]BundleFile, 0),
+	}
+
+	productFiles, err := collectBundleFiles(p.Dir, path.Join(BundleProductsDir, p.Name))
+	if err != nil {
+		return nil, fmt.Errorf("collect product files failed, err: %s", err)
+	}
+	manifest.Files = append(manifest.Files, productFiles...)
+
+	sharedRolesDir := path.Join(p.baseDir, SharedRolesDir)
+	if _, err := os.Stat(sharedRolesDir); err == nil {
+		sharedRolesFiles, err := collectBundleFiles(sharedRolesDir, path.Join(BundleProductsDir, SharedRolesDir))
+		if err != nil {
+			return nil, fmt.Errorf("collect shared roles files failed, err: %s", err)
+		}
+		manifest.Files = append(manifest.Files, sharedRolesFiles...)
+	}
+
+	missing := []string{}
+	for _, pkgFile := range p.PkgFiles() {
+		src := path.Join(packagesDir, pkgFile)
+		stat, err := os.Stat(src)
+		if err != nil {
+			missing = append(missing, src)
+			continue
+		}
+		manifest.Files = append(manifest.Files, BundleFile{
+			Path: path.Join(BundlePackagesDir, filepath.ToSlash(pkgFile)),
+			Size: stat.Size(),
+			src:  src,
+			mode: stat.Mode(),
+		})
+	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("not found pkg files:\n%s", strings.Join(missing, "\n"))
+	}
+
+	for i := range manifest.Files {
+		sum, err := sha256File(manifest.Files[i].src)
+		if err != nil {
+			return nil, fmt.Errorf("compute checksum for file (%s) failed, err: %s", manifest.Files[i].src, err)
+		}
+		manifest.Files[i].SHA256 = sum
+	}
+
+	if err := writeBundle(dstFile, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Unbundle extracts the bundle archive srcFile into productsDir and packagesDir,
+// and verifies the checksums of all extracted files against the manifest.
+// The files are extracted into temporary dirs first, and only moved into place after all of them are verified,
+// so a corrupted or tampered bundle leaves productsDir and packagesDir untouched.
+// It refuses to overwrite an existing product dir unless force is true, the existing product dir is replaced as a whole.
+func Unbundle(srcFile string, productsDir string, packagesDir string, force bool) (*BundleManifest, error) {
+	f, err := os.Open(srcFile)
+	if err != nil {
+		return nil, fmt.Errorf("open bundle file failed, err: %s", err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read gzip failed, err: %s", err)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("read bundle manifest failed, err: %s", err)
+	}
+	if hdr.Name != BundleManifestFile {
+		return nil, fmt.Errorf("the first entry of bundle must be %s, got %s", BundleManifestFile, hdr.Name)
+	}
+	b, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("read bundle manifest failed, err: %s", err)
+	}
+	manifest := &BundleManifest{}
+	if err := yaml.Unmarshal(b, manifest); err != nil {
+		return nil, fmt.Errorf("yaml unmarshal bundle manifest failed, err: %s", err)
+	}
+	if manifest.Product == "" || strings.ContainsAny(manifest.Product, `/\`) || strings.HasPrefix(manifest.Product, ".") {
+		return nil, fmt.Errorf("invalid product name (%s) in bundle manifest", manifest.Product)
+	}
+
+	productDir := path.Join(productsDir, manifest.Product)
+	if _, err := os.Stat(productDir); err == nil && !force {
+		return nil, fmt.Errorf("product dir (%s) already exists, remove it or force overwrite", productDir)
+	}
+
+	// the temporary dirs are created in the destination dirs, so the files can be moved into place by renaming
+	stageProductsDir, err := makeStageDir(productsDir)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stageProductsDir)
+	stagePackagesDir, err := makeStageDir(packagesDir)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stagePackagesDir)
+
+	expected := make(map[string]BundleFile)
+	for _, file := range manifest.Files {
+		expected[file.Path] = file
+	}
+
+	errs := []string{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read bundle failed, err: %s", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		file, ok := expected[hdr.Name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("file (%s) is not declared in manifest", hdr.Name))
+			continue
+		}
+		delete(expected, hdr.Name)
+
+		dst, err := bundleFileDst(hdr.Name, manifest.Product, stageProductsDir, stagePackagesDir)
+		if err != nil {
+			return nil, err
+		}
+
+		sum, err := extractFile(tr, dst, fs.FileMode(hdr.Mode))
+		if err != nil {
+			return nil, fmt.Errorf("extract file (%s) failed, err: %s", hdr.Name, err)
+		}
+		if sum != file.SHA256 {
+			errs = append(errs, fmt.Sprintf("checksum mismatch for file (%s), expected %s, got %s", hdr.Name, file.SHA256, sum))
+		}
+	}
+
+	for name := range expected {
+		errs = append(errs, fmt.Sprintf("file (%s) declared in manifest is missing in bundle", name))
+	}
+
+	if len(errs) != 0 {
+		sort.Strings(errs)
+		return manifest, fmt.Errorf("verify bundle failed, nothing is extracted:\n%s", strings.Join(errs, "\n"))
+	}
+
+	if err := replaceDir(path.Join(stageProductsDir, manifest.Product), productDir); err != nil {
+		return manifest, fmt.Errorf("move product dir into place failed, err: %s", err)
+	}
+	// the shared roles and the packages are shared by the products, so only the files in the bundle are overwritten
+	if err := moveFiles(path.Join(stageProductsDir, SharedRolesDir), path.Join(productsDir, SharedRolesDir)); err != nil {
+		return manifest, fmt.Errorf("move shared roles into place failed, err: %s", err)
+	}
+	if err := moveFiles(stagePackagesDir, packagesDir); err != nil {
+		return manifest, fmt.Errorf("move pkg files into place failed, err: %s", err)
+	}
+
+	return manifest, nil
+}
+
+// makeStageDir creates a hidden temporary dir in dir.
+func makeStageDir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("make dir (%s) failed, err: %s", dir, err)
+	}
+	stageDir, err := os.MkdirTemp(dir, ".unbundle-*")
+	if err != nil {
+		return "", fmt.Errorf("make temporary dir in (%s) failed, err: %s", dir, err)
+	}
+	return stageDir, nil
+}
+
+// replaceDir replaces dstDir with srcDir. The stale files in the existing dstDir are removed.
+func replaceDir(srcDir string, dstDir string) error {
+	if _, err := os.Stat(srcDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	old := ""
+	if _, err := os.Stat(dstDir); err == nil {
+		old = dstDir + ".unbundle-old"
+		if err := os.RemoveAll(old); err != nil {
+			return err
+		}
+		if err := os.Rename(dstDir, old); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(srcDir, dstDir); err != nil {
+		if old != "" {
+			_ = os.Rename(old, dstDir)
+		}
+		return err
+	}
+
+	if old != "" {
+		return os.RemoveAll(old)
+	}
+	return nil
+}
+
+// moveFiles moves all regular files under srcDir into dstDir, the existing files are overwritten.
+func moveFiles(srcDir string, dstDir string) error {
+	if _, err := os.Stat(srcDir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return filepath.WalkDir(srcDir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dstDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+			return err
+		}
+		return os.Rename(p, dst)
+	})
+}
+
+// collectBundleFiles returns all regular files under srcDir,
+// the path of each file in the archive is prefixed with archiveDir.
+func collectBundleFiles(srcDir string, archiveDir string) ([]BundleFile, error) {
+	out := []BundleFile{}
+
+	err := filepath.WalkDir(srcDir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			fmt.Printf("warn: %s is not a regular file, omit\n", p)
+			return nil
+		}
+
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		out = append(out, BundleFile{
+			Path: path.Join(archiveDir, filepath.ToSlash(rel)),
+			Size: info.Size(),
+			src:  p,
+			mode: info.Mode(),
+		})
+		return nil
+	})
+
+	return out, err
+}
+
+func writeBundle(dstFile string, manifest *BundleManifest) error {
+	// write to a temporary file in the same dir first, so no partial bundle file is left on errors
+	f, err := os.CreateTemp(filepath.Dir(dstFile), "."+filepath.Base(dstFile)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create bundle file failed, err: %s", err)
+	}
+	tmp := f.Name()
+
+	if err := writeBundleArchive(f, manifest); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("close bundle file failed, err: %s", err)
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("chmod bundle file failed, err: %s", err)
+	}
+	if err := os.Rename(tmp, dstFile); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename bundle file failed, err: %s", err)
+	}
+
+	return nil
+}
+
+// writeBundleArchive writes the manifest and the files of the manifest to w as a gzipped tar archive.
+func writeBundleArchive(w io.Writer, manifest *BundleManifest) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	b, err := yaml.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("yaml marshal manifest failed, err: %s", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    BundleManifestFile,
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return fmt.Errorf("write manifest header failed, err: %s", err)
+	}
+	if _, err := tw.Write(b); err != nil {
+		return fmt.Errorf("write manifest failed, err: %s", err)
+	}
+
+	for _, file := range manifest.Files {
+		if err := addFileToTar(tw, file); err != nil {
+			return fmt.Errorf("add file (%s) to bundle failed, err: %s", file.src, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close tar writer failed, err: %s", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("close gzip writer failed, err: %s", err)
+	}
+
+	return nil
+}
+
+func addFileToTar(tw *tar.Writer, file BundleFile) error {
+	f, err := os.Open(file.src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    file.Path,
+		Mode:    int64(file.mode.Perm()),
+		Size:    file.Size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// bundleFileDst maps the archive path of a file to its local path.
+// The files under products dir must belong to the product or the shared roles.
+func bundleFileDst(name string, productName string, productsDir string, packagesDir string) (string, error) {
+	cleaned := path.Clean(name)
+	if strings.HasPrefix(cleaned, "../") || path.IsAbs(cleaned) {
+		return "", fmt.Errorf("invalid file path (%s) in bundle", name)
+	}
+
+	switch {
+	case strings.HasPrefix(cleaned, BundleProductsDir+"/"):
+		rel := strings.TrimPrefix(cleaned, BundleProductsDir+"/")
+		if !strings.HasPrefix(rel, productName+"/") && !strings.HasPrefix(rel, SharedRolesDir+"/") {
+			return "", fmt.Errorf("invalid file path (%s) in bundle, it does not belong to product (%s)", name, productName)
+		}
+		return filepath.Join(productsDir, filepath.FromSlash(rel)), nil
+	case strings.HasPrefix(cleaned, BundlePackagesDir+"/"):
+		return filepath.Join(packagesDir, filepath.FromSlash(strings.TrimPrefix(cleaned, BundlePackagesDir+"/"))), nil
+	default:
+		return "", fmt.Errorf("invalid file path (%s) in bundle", name)
+	}
+}
+
+// extractFile writes the content of r to dst, and returns the sha256 checksum of the content.
+func extractFile(r io.Reader, dst string, mode fs.FileMode) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	if mode.Perm() == 0 {
+		mode = 0644
+	}
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sha256File(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,117 @@
+package product
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestUnbundle(t *testing.T) {
+	srcDir := t.TempDir()
+	for name, content := range map[string]string{
+		"products/foobar/vars.yaml":       "installDir: /opt\n",
+		"products/foobar/components.yaml": "foobar-api:\n  pkgs:\n    - file: api/foobar-api.tgz\n",
+		"packages/api/foobar-api.tgz":     "foobar-api package",
+	} {
+		f := path.Join(srcDir, name)
+		if err := os.MkdirAll(path.Dir(f), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewProduct("foobar", path.Join(srcDir, "products"))
+	p.Components["foobar-api"] = &Component{Name: "foobar-api", Pkgs: []Pkg{{File: strPtr("api/foobar-api.tgz")}}}
+	bundleFile := path.Join(srcDir, "foobar.tar.gz")
+	manifest, err := p.Bundle(path.Join(srcDir, "packages"), bundleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tampered bundle
+	manifest.Files[0].SHA256 = "0000"
+	tamperedFile := path.Join(srcDir, "tampered.tar.gz")
+	if err := writeBundle(tamperedFile, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	dstDir := t.TempDir()
+	productsDir, packagesDir := path.Join(dstDir, "products"), path.Join(dstDir, "packages")
+	staleFile := path.Join(productsDir, "foobar", "stale.yaml")
+	if err := os.MkdirAll(path.Dir(staleFile), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(staleFile, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Unbundle(bundleFile, productsDir, packagesDir, false); err == nil {
+		t.Error("expected error for existing product dir without force")
+	}
+
+	// nothing is extracted from the tampered bundle
+	if _, err := Unbundle(tamperedFile, productsDir, packagesDir, true); err == nil {
+		t.Error("expected error for tampered bundle")
+	}
+	if _, err := os.Stat(staleFile); err != nil {
+		t.Errorf("expected the existing product dir untouched, err: %s", err)
+	}
+	if _, err := os.Stat(path.Join(productsDir, "foobar", "vars.yaml")); err == nil {
+		t.Error("expected no files extracted from the tampered bundle")
+	}
+
+	// the files of the other products are rejected, even if they are declared in manifest
+	varsFile := path.Join(srcDir, "products", "foobar", "vars.yaml")
+	sum, err := sha256File(varsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherFile := path.Join(srcDir, "other.tar.gz")
+	if err := writeBundle(otherFile, &BundleManifest{
+		Product: "foobar",
+		Files:   []BundleFile{{Path: "products/other/vars.yaml", Size: int64(len("installDir: /opt\n")), SHA256: sum, src: varsFile}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unbundle(otherFile, productsDir, packagesDir, true); err == nil || !strings.Contains(err.Error(), "does not belong to product") {
+		t.Errorf("expected error for the file of other product, got %v", err)
+	}
+	if _, err := os.Stat(path.Join(productsDir, "other")); err == nil {
+		t.Error("expected the file of other product not extracted")
+	}
+
+	// no partial bundle file is left on errors
+	brokenFile := path.Join(srcDir, "broken.tar.gz")
+	if err := writeBundle(brokenFile, &BundleManifest{
+		Product: "foobar",
+		Files:   []BundleFile{{Path: "packages/not-exist", src: path.Join(srcDir, "not-exist")}},
+	}); err == nil {
+		t.Error("expected error for the missing file")
+	}
+	if _, err := os.Stat(brokenFile); err == nil {
+		t.Error("expected no partial bundle file left")
+	}
+
+	if _, err := Unbundle(bundleFile, productsDir, packagesDir, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(staleFile); err == nil {
+		t.Error("expected the stale file removed")
+	}
+	for _, f := range []string{path.Join(productsDir, "foobar", "vars.yaml"), path.Join(packagesDir, "api", "foobar-api.tgz")} {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("expected file (%s) extracted, err: %s", f, err)
+		}
+	}
+
+	entries, err := os.ReadDir(productsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the product dir left in products dir, got %d entries", len(entries))
+	}
+}
@@ -2,6 +2,7 @@ package product
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/bougou/sail/pkg/ansible"
@@ -52,13 +53,15 @@ type Component struct {
 	// Requires represents the other components on which this component depends on.
 	// If this component is activated (enabled:true or external:true), then all these required components also need to be activated.
 	// 依赖的服务（其它组件提供的服务，不能依赖自身组件）
-	// Todo, check cycle
+	// The requires of all components MUST NOT form a cycle, it is checked when loading the product.
+	// The requires also determine the default order of plays in the auto generated sail playbook.
 	Requires []Require `yaml:"requires"`
 
 	// The list value of `deps` represents the other services which depend on this service.
 	// If the number of hosts of this service changed, it required that
 	// those services who depend on it also need to be reconfigured or restarted.
-	// For `service-scaleup` and `service-scaledown`, these dependencies will be used
+	// For `sail scale-up` and `sail scale-down`, the reconfigure plays (tagged with `reconfigure-<componentName>`)
+	// of these dependencies will be automatically re-run.
 	Dependencies []string `yaml:"dependencies"`
 
 	// Children represents some children-level components of this component.
@@ -104,21 +107,24 @@ func (c *Component) Merge(in *Component) {
 
 }
 
-// Pkg represents a package file.
-// We can use the Pkg.File field to check whether those pkg files exists
-// and use the Pkg.URL field to download the file.
-type Pkg struct {
-	File *string `yaml:"file"`
-	URL  *string `yaml:"url"`
-}
-
 type Require struct {
 	Component *string `yaml:"component,omitempty"`
 	Service   *string `yaml:"service,omitempty"`
 }
 
-func (c *Component) DownloadPkg(dstDir string) error {
-	// Todo
+// DownloadPkg downloads the pkg files of the component into dstDir.
+// The pkg files which already exist (and match the checksum if declared) are skipped.
+func (c *Component) DownloadPkg(dstDir string, out io.Writer) error {
+	errs := []string{}
+	for _, pkg := range c.Pkgs {
+		if err := pkg.Download(dstDir, out); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("download pkgs for component (%s) failed, err: %s", c.Name, strings.Join(errs, "; "))
+	}
 	return nil
 }
 
@@ -0,0 +1,158 @@
+package product
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// requiresGraph returns the dependency graph of the components built from their requires.
+// The key is the component name, the value is the names of the components it requires.
+// The requires which can not be resolved are not in the graph, they are returned as warnings.
+func (p *Product) requiresGraph() (map[string][]string, []string) {
+	graph := make(map[string][]string)
+	warnings := []string{}
+
+	for _, componentName := range p.ComponentList() {
+		required := []string{}
+		for _, r := range p.Components[componentName].Requires {
+			name, err := p.ResolveRequire(r)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("ignore the require of component (%s), %s", componentName, err))
+				continue
+			}
+			required = append(required, name)
+		}
+		required = dedupSliceString(required)
+		sort.Strings(required)
+		graph[componentName] = required
+	}
+
+	return graph, warnings
+}
+
+// checkRequiresCycle returns error if the requires of the components form a cycle.
+func checkRequiresCycle(graph map[string][]string) error {
+	if cycle := findCycle(graph); len(cycle) != 0 {
+		return fmt.Errorf("found cycle in requires of components: %s", strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
+
+// findCycle returns the first found cycle path (the first and the last elements are same) in the graph,
+// or nil if the graph has no cycle.
+func findCycle(graph map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int)
+	stack := []string{}
+
+	var visit func(node string) []string
+	visit = func(node string) []string {
+		state[node] = visiting
+		stack = append(stack, node)
+
+		for _, next := range graph[node] {
+			switch state[next] {
+			case visiting:
+				for i, v := range stack {
+					if v == next {
+						cycle := append([]string{}, stack[i:]...)
+						return append(cycle, next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[node] = visited
+		return nil
+	}
+
+	nodes := []string{}
+	for node := range graph {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			if cycle := visit(node); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// topoSort returns the nodes of the graph ordered so that every node comes after the nodes it requires.
+// Nodes which have no order relationship are sorted alphabetically.
+// The graph MUST NOT have cycles.
+func topoSort(graph map[string][]string) []string {
+	// the number of not yet ordered requires for each node
+	pending := make(map[string]int)
+	// node -> the nodes which require it
+	dependents := make(map[string][]string)
+
+	for node, requires := range graph {
+		pending[node] += 0
+		for _, r := range requires {
+			pending[node]++
+			pending[r] += 0
+			dependents[r] = append(dependents[r], node)
+		}
+	}
+
+	ready := []string{}
+	for node, n := range pending {
+		if n == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	out := []string{}
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		node := ready[0]
+		ready = ready[1:]
+		out = append(out, node)
+
+		for _, dependent := range dependents[node] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	return out
+}
+
+// checkOrderConflicts returns the messages describing where the order contradicts the requires graph.
+func checkOrderConflicts(order []string, graph map[string][]string) []string {
+	index := make(map[string]int)
+	for i, v := range order {
+		index[v] = i
+	}
+
+	msgs := []string{}
+	for _, node := range order {
+		for _, r := range graph[node] {
+			if index[r] > index[node] {
+				msgs = append(msgs, fmt.Sprintf("component (%s) is ordered before its required component (%s)", node, r))
+			}
+		}
+	}
+
+	return msgs
+}
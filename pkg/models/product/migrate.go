@@ -0,0 +1,233 @@
+package product
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Migration represents a versioned migration declared in the migrate.yaml file of the product.
+// When the operation code of the product evolves (eg: a variable is renamed),
+// the migrations are used to convert the existing zone vars to the new structure.
+//
+//	# products/<productName>/migrate.yaml
+//	- version: 1
+//	  description: "rename redis_pass to redis.vars.pass"
+//	  steps:
+//	    - moveVar: { from: redis_pass, component: redis, to: pass }
+//	    - renameVar: { from: tz, to: timezone }
+//	    - deleteVar: { name: obsolete_var }
+//	    - setDefault: { name: log_level, value: info }
+//	    - renameComponent: { from: old-redis, to: redis }
+type Migration struct {
+	Version     int             `yaml:"version"`
+	Description string          `yaml:"description"`
+	Steps       []MigrationStep `yaml:"steps"`
+}
+
+// MigrationStep holds exactly one action.
+type MigrationStep struct {
+	RenameVar       *RenameStep     `yaml:"renameVar,omitempty"`
+	MoveVar         *MoveVarStep    `yaml:"moveVar,omitempty"`
+	DeleteVar       *DeleteVarStep  `yaml:"deleteVar,omitempty"`
+	SetDefault      *SetDefaultStep `yaml:"setDefault,omitempty"`
+	RenameComponent *RenameStep     `yaml:"renameComponent,omitempty"`
+}
+
+type RenameStep struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// MoveVarStep moves the top level variable `from` into the vars of the component as `to`.
+// If `to` is empty, the name of the variable is kept.
+// Like renaming, the value of `from` overrides the existing value of `to`.
+type MoveVarStep struct {
+	From      string `yaml:"from"`
+	Component string `yaml:"component"`
+	To        string `yaml:"to"`
+}
+
+type DeleteVarStep struct {
+	Name string `yaml:"name"`
+}
+
+// SetDefaultStep sets the top level variable to the value only if the variable does not exist.
+type SetDefaultStep struct {
+	Name  string      `yaml:"name"`
+	Value interface{} `yaml:"value"`
+}
+
+// LoadMigrations returns the migrations declared in the migrate.yaml file of the product.
+// The migrate.yaml file is optional.
+func (p *Product) LoadMigrations() ([]Migration, error) {
+	migrations := []Migration{}
+
+	b, err := os.ReadFile(p.migrateFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return migrations, nil
+		}
+		return nil, fmt.Errorf("read file (%s) failed, err: %s", p.migrateFile, err)
+	}
+
+	if err := yamlUnmarshalStrict(b, &migrations); err != nil {
+		return nil, fmt.Errorf("unmarshal migrations for product (%s) failed, err: %s", p.Name, err)
+	}
+
+	lastVersion := 0
+	for _, m := range migrations {
+		if m.Version <= lastVersion {
+			return nil, fmt.Errorf("the versions of migrations must be positive and increasing, found (%d) after (%d)", m.Version, lastVersion)
+		}
+		lastVersion = m.Version
+
+		for i, step := range m.Steps {
+			if err := step.validate(); err != nil {
+				return nil, fmt.Errorf("invalid step #%d of migration (%d), err: %s", i+1, m.Version, err)
+			}
+		}
+	}
+
+	return migrations, nil
+}
+
+// LatestMigrationVersion returns the version of the last migration of the product, or 0 if no migrations.
+func (p *Product) LatestMigrationVersion() (int, error) {
+	migrations, err := p.LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+func (s *MigrationStep) validate() error {
+	n := 0
+	if s.RenameVar != nil {
+		n++
+		if s.RenameVar.From == "" || s.RenameVar.To == "" {
+			return errors.New("renameVar requires from and to")
+		}
+	}
+	if s.MoveVar != nil {
+		n++
+		if s.MoveVar.From == "" || s.MoveVar.Component == "" {
+			return errors.New("moveVar requires from and component")
+		}
+	}
+	if s.DeleteVar != nil {
+		n++
+		if s.DeleteVar.Name == "" {
+			return errors.New("deleteVar requires name")
+		}
+	}
+	if s.SetDefault != nil {
+		n++
+		if s.SetDefault.Name == "" {
+			return errors.New("setDefault requires name")
+		}
+	}
+	if s.RenameComponent != nil {
+		n++
+		if s.RenameComponent.From == "" || s.RenameComponent.To == "" {
+			return errors.New("renameComponent requires from and to")
+		}
+	}
+
+	if n != 1 {
+		return fmt.Errorf("each step must have exactly one action, got (%d)", n)
+	}
+	return nil
+}
+
+// Apply applies all steps of the migration to the zone vars m.
+func (mig *Migration) Apply(m map[string]interface{}) error {
+	for i, step := range mig.Steps {
+		if err := step.apply(m); err != nil {
+			return fmt.Errorf("apply step #%d of migration (%d) failed, err: %s", i+1, mig.Version, err)
+		}
+	}
+	return nil
+}
+
+func (s *MigrationStep) apply(m map[string]interface{}) error {
+	switch {
+	case s.RenameVar != nil:
+		return renameKey(m, s.RenameVar.From, s.RenameVar.To)
+
+	case s.RenameComponent != nil:
+		return renameKey(m, s.RenameComponent.From, s.RenameComponent.To)
+
+	case s.DeleteVar != nil:
+		delete(m, s.DeleteVar.Name)
+		return nil
+
+	case s.SetDefault != nil:
+		if _, exists := m[s.SetDefault.Name]; !exists {
+			m[s.SetDefault.Name] = s.SetDefault.Value
+		}
+		return nil
+
+	case s.MoveVar != nil:
+		v, exists := m[s.MoveVar.From]
+		if !exists {
+			return nil
+		}
+		to := s.MoveVar.To
+		if to == "" {
+			to = s.MoveVar.From
+		}
+
+		if _, exists := m[s.MoveVar.Component]; !exists {
+			m[s.MoveVar.Component] = map[string]interface{}{}
+		}
+		component, ok := m[s.MoveVar.Component].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("the value of component (%s) is not a map", s.MoveVar.Component)
+		}
+		if _, exists := component["vars"]; !exists || component["vars"] == nil {
+			component["vars"] = map[string]interface{}{}
+		}
+		componentVars, ok := component["vars"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("the vars of component (%s) is not a map", s.MoveVar.Component)
+		}
+		componentVars[to] = v
+		delete(m, s.MoveVar.From)
+		return nil
+	}
+
+	return nil
+}
+
+// renameKey renames the key `from` to `to` in the map.
+// It is a no-op if `from` does not exist.
+// The value of `to` is overridden if it already exists (eg: it may be filled with
+// the default value when the zone was dumped by the new product code before migrating).
+func renameKey(m map[string]interface{}, from string, to string) error {
+	v, exists := m[from]
+	if !exists {
+		return nil
+	}
+
+	m[to] = v
+	delete(m, from)
+	return nil
+}
+
+// yamlUnmarshalStrict is like yaml.Unmarshal, but returns error for unknown fields.
+func yamlUnmarshalStrict(b []byte, out interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
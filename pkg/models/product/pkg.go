@@ -0,0 +1,307 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the suffix of the temporary file when downloading a pkg file,
+// it is kept on failure so the next download can resume from it.
+const partialFileSuffix = ".part"
+
+// Pkg represents a package file.
+// We can use the Pkg.File field to check whether those pkg files exists
+// and use the Pkg.URL field to download the file.
+type Pkg struct {
+	File *string `yaml:"file"`
+	URL  *string `yaml:"url"`
+
+	// SHA256 is the optional hex encoded sha256 checksum of the file.
+	SHA256 *string `yaml:"sha256,omitempty"`
+}
+
+// Download fetches the pkg file from its URL into dstDir.
+// It is a no-op if the pkg does not declare both file and url,
+// or the file already exists under dstDir and matches the checksum.
+// The existing file which does not match the checksum is removed and downloaded again.
+//
+// The supported url schemes are http, https and file.
+// An interrupted download is resumed from the partial file left by the previous try.
+// The progress is written to out.
+func (pkg *Pkg) Download(dstDir string, out io.Writer) error {
+	if pkg.File == nil || *pkg.File == "" {
+		return nil
+	}
+
+	dst := path.Join(dstDir, *pkg.File)
+
+	// the same pkg file may be downloaded by multiple zones concurrently
+	unlock := lockPath(dst)
+	defer unlock()
+
+	hasURL := pkg.URL != nil && *pkg.URL != ""
+
+	if _, err := os.Stat(dst); err == nil {
+		err := pkg.verify(dst)
+		if err == nil {
+			return nil
+		}
+		if !hasURL {
+			return fmt.Errorf("pkg file (%s) already exists, but %s", dst, err)
+		}
+
+		// the existing file is broken (eg: replaced by a corrupted copy), download it again
+		fmt.Fprintf(out, "pkg file (%s) already exists, but %s, download it again\n", dst, err)
+		if err := os.Remove(dst); err != nil {
+			return fmt.Errorf("remove broken pkg file (%s) failed, err: %s", dst, err)
+		}
+	}
+
+	if !hasURL {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return fmt.Errorf("create dir for pkg file (%s) failed, err: %s", dst, err)
+	}
+
+	fmt.Fprintf(out, "downloading %s to %s\n", *pkg.URL, dst)
+	partial := dst + partialFileSuffix
+	if err := fetch(*pkg.URL, partial, pkg.SHA256 != nil && *pkg.SHA256 != ""); err != nil {
+		return fmt.Errorf("download (%s) failed, err: %s", *pkg.URL, err)
+	}
+
+	if err := pkg.verify(partial); err != nil {
+		// the partial file is broken, it can not be resumed
+		_ = os.Remove(partial)
+		return fmt.Errorf("downloaded file from (%s) is broken, %s", *pkg.URL, err)
+	}
+
+	if err := os.Rename(partial, dst); err != nil {
+		return fmt.Errorf("rename (%s) to (%s) failed, err: %s", partial, dst, err)
+	}
+
+	return nil
+}
+
+// verify checks the file against the declared sha256 checksum.
+func (pkg *Pkg) verify(file string) error {
+	if pkg.SHA256 == nil || *pkg.SHA256 == "" {
+		return nil
+	}
+
+	sum, err := sha256File(file)
+	if err != nil {
+		return fmt.Errorf("compute checksum failed, err: %s", err)
+	}
+
+	if !strings.EqualFold(sum, *pkg.SHA256) {
+		return fmt.Errorf("checksum mismatch, expected %s, got %s", *pkg.SHA256, sum)
+	}
+
+	return nil
+}
+
+// fetch downloads rawURL into dst, appending to dst if it already has content.
+// If checked is false (no checksum to verify the complete file), a partial file which the server
+// refuses to resume (http 416) is downloaded again from the beginning, instead of being treated as complete.
+func fetch(rawURL string, dst string, checked bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("parse url failed, err: %s", err)
+	}
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	var body io.ReadCloser
+	switch u.Scheme {
+	case "file":
+		src, err := os.Open(u.Path)
+		if err != nil {
+			return err
+		}
+		if stat, err := src.Stat(); err == nil && offset > stat.Size() {
+			// the partial file is oversized, it must be stale, copy from the beginning
+			if err := truncateFile(f); err != nil {
+				src.Close()
+				return err
+			}
+			offset = 0
+		}
+		if _, err := src.Seek(offset, io.SeekStart); err != nil {
+			src.Close()
+			return err
+		}
+		body = src
+
+	case "http", "https":
+		// the request is canceled if no data is received in fetchIdleTimeout
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		timer := time.AfterFunc(fetchIdleTimeout, cancel)
+		defer timer.Stop()
+
+		resp, err := httpGet(ctx, rawURL, offset)
+		if err != nil {
+			return err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+		case http.StatusOK:
+			// the server does not support range requests, download from the beginning
+			if err := truncateFile(f); err != nil {
+				resp.Body.Close()
+				return err
+			}
+		case http.StatusRequestedRangeNotSatisfiable:
+			resp.Body.Close()
+			if checked {
+				// the partial file may be already complete, it is verified by the checksum
+				return nil
+			}
+			// the partial file can not be verified, it may be stale or oversized, download from the beginning
+			if err := truncateFile(f); err != nil {
+				return err
+			}
+			resp, err = httpGet(ctx, rawURL, 0)
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return fmt.Errorf("unexpected http status: %s", resp.Status)
+			}
+		default:
+			resp.Body.Close()
+			return fmt.Errorf("unexpected http status: %s", resp.Status)
+		}
+		body = &idleTimeoutReader{ReadCloser: resp.Body, timer: timer}
+
+	default:
+		return fmt.Errorf("not supported url scheme (%s)", u.Scheme)
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(f, body); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("no data received in %s", fetchIdleTimeout)
+		}
+		return err
+	}
+
+	return nil
+}
+
+const (
+	// fetchTimeout is the timeout of connecting to the server and waiting for the response headers
+	fetchTimeout = 30 * time.Second
+	// fetchIdleTimeout is the max duration without receiving any data when downloading a pkg file,
+	// the whole download is not limited as the pkg files may be large
+	fetchIdleTimeout = 60 * time.Second
+)
+
+// fetchClient is the http client of downloading the pkg files, the stalled servers are not waited forever.
+var fetchClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: fetchTimeout}).DialContext,
+		TLSHandshakeTimeout:   fetchTimeout,
+		ResponseHeaderTimeout: fetchTimeout,
+	},
+}
+
+// httpGet requests rawURL from the offset, the whole content is requested if offset is 0.
+func httpGet(ctx context.Context, rawURL string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	return fetchClient.Do(req)
+}
+
+func truncateFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+// idleTimeoutReader resets the idle timer on each read.
+type idleTimeoutReader struct {
+	io.ReadCloser
+	timer *time.Timer
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.timer.Reset(fetchIdleTimeout)
+	return n, err
+}
+
+// DownloadPkgs downloads the pkg files of the specified components into dstDir.
+// All components are considered if componentNames is empty.
+func (p *Product) DownloadPkgs(dstDir string, out io.Writer, componentNames ...string) error {
+	if len(componentNames) == 0 {
+		componentNames = p.ComponentList()
+	}
+
+	errs := []string{}
+	for _, componentName := range componentNames {
+		c, ok := p.Components[componentName]
+		if !ok {
+			return fmt.Errorf("not found component (%s) in product", componentName)
+		}
+		if err := c.DownloadPkg(dstDir, out); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+var (
+	pathLocksMu sync.Mutex
+	pathLocks   = make(map[string]*sync.Mutex)
+)
+
+// lockPath locks the path within the process, and returns the function to unlock it.
+func lockPath(p string) func() {
+	pathLocksMu.Lock()
+	l, ok := pathLocks[p]
+	if !ok {
+		l = &sync.Mutex{}
+		pathLocks[p] = l
+	}
+	pathLocksMu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
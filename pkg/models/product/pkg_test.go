@@ -0,0 +1,106 @@
+package product
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestPkg_Download(t *testing.T) {
+	content := strings.Repeat("sail package content\n", 100)
+	h := sha256.Sum256([]byte(content))
+	sum := hex.EncodeToString(h[:])
+
+	srcDir := t.TempDir()
+	srcFile := path.Join(srcDir, "foo.tar.gz")
+	if err := os.WriteFile(srcFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "foo.tar.gz", time.Now(), strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		sha256   string
+		partial  string
+		existing string
+		wantErr  bool
+	}{
+		{name: "file", url: "file://" + srcFile, sha256: sum},
+		{name: "http", url: server.URL + "/foo.tar.gz", sha256: sum},
+		{name: "http resume", url: server.URL + "/foo.tar.gz", sha256: sum, partial: content[:100]},
+		{name: "file resume", url: "file://" + srcFile, partial: content[:100]},
+		{name: "http oversized partial", url: server.URL + "/foo.tar.gz", partial: content + "stale content"},
+		{name: "file oversized partial", url: "file://" + srcFile, partial: content + "stale content"},
+		{name: "broken existing", url: server.URL + "/foo.tar.gz", sha256: sum, existing: content[:100]},
+		{name: "checksum mismatch", url: server.URL + "/foo.tar.gz", sha256: strings.Repeat("0", 64), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dstDir := t.TempDir()
+			pkg := &Pkg{
+				File: strPtr("files/foo.tar.gz"),
+				URL:  strPtr(tt.url),
+			}
+			if tt.sha256 != "" {
+				pkg.SHA256 = strPtr(tt.sha256)
+			}
+
+			dst := path.Join(dstDir, "files", "foo.tar.gz")
+			if err := os.MkdirAll(path.Dir(dst), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			if tt.partial != "" {
+				if err := os.WriteFile(dst+partialFileSuffix, []byte(tt.partial), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.existing != "" {
+				if err := os.WriteFile(dst, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := pkg.Download(dstDir, io.Discard)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Download() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, err := os.Stat(dst); err == nil {
+					t.Errorf("broken file should not be kept as %s", dst)
+				}
+				return
+			}
+
+			b, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != content {
+				t.Errorf("downloaded content mismatch, got %d bytes, want %d bytes", len(b), len(content))
+			}
+
+			// the existing file should be skipped even if the url is not reachable anymore
+			pkg.URL = strPtr("http://127.0.0.1:0/not-exist")
+			if err := pkg.Download(dstDir, io.Discard); err != nil {
+				t.Errorf("Download() existing file error = %v", err)
+			}
+		})
+	}
+}
@@ -30,6 +30,8 @@ type Product struct {
 
 	// default vars of product, it is loaded from products/<productName>/vars.yaml
 	DefaultVars map[string]interface{}
+	// the key order of the default vars in products/<productName>/vars.yaml
+	varsOrder []string
 	// default components of product, it is loaded from products/<productName>/{components.yaml,components/*.yaml}
 	DefaultComponents map[string]Component
 
@@ -103,18 +105,39 @@ func (p *Product) Init() error {
 		return fmt.Errorf("load product (%s) components failed, err: %s", p.Name, err)
 	}
 
-	if err := p.loadOrder(); err != nil {
+	// only the cycles fail, the requires which can not be resolved are warned
+	graph, warnings := p.requiresGraph()
+	for _, msg := range warnings {
+		fmt.Fprintf(os.Stderr, "warn: %s\n", msg)
+	}
+	if err := checkRequiresCycle(graph); err != nil {
+		return fmt.Errorf("check product (%s) requires failed, err: %s", p.Name, err)
+	}
+
+	if err := p.loadOrder(graph); err != nil {
 		return fmt.Errorf("load product (%s) order from file (%s) failed, err: %s", p.Name, p.orderFile, err)
 	}
 
 	return nil
 }
 
+// InitComponents only loads the default components of the product,
+// the vars, the requires and the order are not loaded. It is used for the overview of zones.
+func (p *Product) InitComponents() error {
+	if err := p.loadDefaultComponents(); err != nil {
+		return fmt.Errorf("load product (%s) components failed, err: %s", p.Name, err)
+	}
+	return nil
+}
+
 func (p *Product) HasComponent(name string) bool {
 	_, exists := p.Components[name]
 	return exists
 }
 
+// SetComponentEnabled sets the enabled field of the component.
+// It does not touch the required components, use InactiveRequires and ActiveDependents
+// to find out the components which also need to be changed.
 func (p *Product) SetComponentEnabled(name string, flag bool) error {
 	if !p.HasComponent(name) {
 		return fmt.Errorf("can not enable component, the product does not have this component (%s)", name)
@@ -125,11 +148,12 @@ func (p *Product) SetComponentEnabled(name string, flag bool) error {
 		p.Components[name].External = false
 	}
 
-	// Todo,
-	// if this component is (enabled:true or external:true), we should automatically set all its depenent components to be (enabled:true or external:true)
 	return nil
 }
 
+// SetComponentExternalEnabled sets the external field of the component.
+// It does not touch the required components, use InactiveRequires and ActiveDependents
+// to find out the components which also need to be changed.
 func (p *Product) SetComponentExternalEnabled(name string, flag bool) error {
 	if !p.HasComponent(name) {
 		return fmt.Errorf("can not enable component, the product does not have this component (%s)", name)
@@ -140,9 +164,6 @@ func (p *Product) SetComponentExternalEnabled(name string, flag bool) error {
 		p.Components[name].Enabled = false
 	}
 	return nil
-
-	// Todo,
-	// if this component is (enabled:true or external:true), we should automatically set all its depenent components to be (enabled:true or external:true)
 }
 
 func (p *Product) ComponentList() []string {
@@ -174,6 +195,29 @@ func FilterOptionFormServer(c *Component) bool {
 	return c.Form != ComponentFormPod
 }
 
+func FilterOptionExternal(c *Component) bool {
+	return c.External
+}
+
+// NewFilterOptionByForm returns a FilterOption which chooses the components of the form.
+func NewFilterOptionByForm(form string) (FilterOption, error) {
+	switch form {
+	case ComponentFormPod:
+		return FilterOptionFormPod, nil
+	case ComponentFormServer:
+		return FilterOptionFormServer, nil
+	default:
+		return nil, fmt.Errorf("not supported component form (%s), valid values: server, pod", form)
+	}
+}
+
+// NewFilterOptionByGroup returns a FilterOption which chooses the components of the group.
+func NewFilterOptionByGroup(group string) FilterOption {
+	return func(c *Component) bool {
+		return c.Group == group
+	}
+}
+
 func NewFilterOptionByComponentsMap(m map[string]string) FilterOption {
 	return func(c *Component) bool {
 		if _, ok := m[c.Name]; ok {
@@ -274,6 +318,7 @@ func (p *Product) loadDefaultVars() error {
 	if err := yaml.Unmarshal(b, &p.DefaultVars); err != nil {
 		return fmt.Errorf("unmarshal vars for product (%s) failed, err: %s", p.Name, err)
 	}
+	p.varsOrder = yamlMappingKeys(b)
 
 	m := make(map[string]interface{})
 	if err := copier.Copy(&m, p.DefaultVars); err != nil {
@@ -285,6 +330,32 @@ func (p *Product) loadDefaultVars() error {
 	return nil
 }
 
+// VarsOrder returns the keys of the default vars in the order of the products/<productName>/vars.yaml file.
+// The vars file is read if the product is not initialized, and nil is returned if it can not be read.
+func (p *Product) VarsOrder() []string {
+	if p.varsOrder == nil {
+		if b, err := os.ReadFile(p.varsFile); err == nil {
+			p.varsOrder = yamlMappingKeys(b)
+		}
+	}
+	return p.varsOrder
+}
+
+// yamlMappingKeys returns the top level keys of the yaml mapping document b in their order.
+func yamlMappingKeys(b []byte) []string {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+
+	keys := []string{}
+	mapping := doc.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keys = append(keys, mapping.Content[i].Value)
+	}
+	return keys
+}
+
 // loadDefaultComponents will fill p.DefaultComponents with product operation code,
 // and copy p.DefaultComponents to p.Components
 func (p *Product) loadDefaultComponents() error {
@@ -377,10 +448,21 @@ func (p *Product) LoadZone(zoneVarsFile string) error {
 		return fmt.Errorf("read file failed, err: %s", err)
 	}
 
+	if ansible.IsVaulted(b) {
+		return fmt.Errorf("the file (%s) is encrypted by ansible-vault, it must be decrypted before loading", zoneVarsFile)
+	}
+
+	return p.LoadZoneVars(b)
+}
+
+// LoadZoneVars is like LoadZone, but the zone's specific variables are read from the content of the zone vars file.
+// The values encrypted by ansible-vault are kept encrypted.
+func (p *Product) LoadZoneVars(b []byte) error {
 	m := map[string]interface{}{}
 	if err := yaml.Unmarshal(b, &m); err != nil {
 		return fmt.Errorf("unmarshal vars for failed, err: %s", err)
 	}
+	ansible.MarkVaulted(m)
 
 	for varKey, varValue := range m {
 		// varKey is not a component name
@@ -413,8 +495,9 @@ func (p *Product) LoadZone(zoneVarsFile string) error {
 
 		// make some auto corrections
 		c := p.Components[varKey]
+		ansible.MarkVaulted(c.Vars)
 		if c.Enabled && c.External {
-			fmt.Printf("enabled and external of component can not be both true, automatically set enabled to false for component (%s)\n", varKey)
+			fmt.Fprintf(os.Stderr, "warn: enabled and external of component can not be both true, automatically set enabled to false for component (%s)\n", varKey)
 			c.Enabled = false
 		}
 
@@ -431,6 +514,10 @@ func (p *Product) Check(cm *cmdb.CMDB) error {
 		}
 	}
 
+	if err := p.CheckPortsConflict(cm); err != nil {
+		errs = append(errs, err)
+	}
+
 	if len(errs) == 0 {
 		return nil
 	}
@@ -440,32 +527,118 @@ func (p *Product) Check(cm *cmdb.CMDB) error {
 		errmsgs = append(errmsgs, err.Error())
 	}
 
-	// Todo call checkPortsConflict
-	if err := p.checkPortsConflict(cm); err != nil {
-		errmsg := "check port conflict failed"
-		errmsgs = append(errmsgs, errmsg)
+	return fmt.Errorf("check product (%s) faield, err: %s", p.Name, strings.Join(errmsgs, "; "))
+}
+
+// PortConflict represents two services listening on a same port of a same host.
+type PortConflict struct {
+	Host string
+	Port int
+
+	Component      string
+	Service        string
+	OtherComponent string
+	OtherService   string
+}
+
+func (pc PortConflict) String() string {
+	return fmt.Sprintf("host (%s) port (%d) is used by both %s/%s and %s/%s", pc.Host, pc.Port, pc.Component, pc.Service, pc.OtherComponent, pc.OtherService)
+}
+
+// CheckPortsConflict returns an error listing all port conflicts found by PortsConflicts.
+func (p *Product) CheckPortsConflict(cm *cmdb.CMDB) error {
+	conflicts := p.PortsConflicts(cm)
+	if len(conflicts) == 0 {
+		return nil
 	}
 
-	return fmt.Errorf("check product (%s) faield, err: %s", p.Name, strings.Join(errmsgs, "; "))
+	msgs := []string{}
+	for _, conflict := range conflicts {
+		msgs = append(msgs, conflict.String())
+	}
+
+	return fmt.Errorf("found (%d) ports conflicts:\n%s", len(conflicts), strings.Join(msgs, "\n"))
 }
 
-// checkPortsConflict
-// Todo
-// if multiple components are installed on same hosts, the listened ports of those components may be conflicted.
-func (p *Product) checkPortsConflict(cm *cmdb.CMDB) error {
-	return nil
+// PortsConflicts returns the port conflicts of the services on each host.
+// If multiple components are installed on same hosts, the listened ports of those components may be conflicted.
+// Only the enabled (thus non external) components are considered,
+// the port, pubPort and lbPort of each service are all treated as used ports of the host.
+func (p *Product) PortsConflicts(cm *cmdb.CMDB) []PortConflict {
+	type user struct {
+		component string
+		service   string
+	}
+
+	// host -> port -> the first service which uses the port
+	used := make(map[string]map[int]user)
+	conflicts := []PortConflict{}
+
+	for _, componentName := range p.ComponentListWithFilterOptionsAnd(FilterOptionEnabled) {
+		c := p.Components[componentName]
+		if c.External {
+			continue
+		}
+
+		hosts := cm.GetHostsForComponent(componentName)
+		sort.Strings(hosts)
+
+		svcNames := []string{}
+		for svcName := range c.Services {
+			svcNames = append(svcNames, svcName)
+		}
+		sort.Strings(svcNames)
+
+		for _, host := range hosts {
+			if _, ok := used[host]; !ok {
+				used[host] = make(map[int]user)
+			}
+
+			for _, svcName := range svcNames {
+				svc := c.Services[svcName]
+				for _, port := range dedupSliceInt([]int{svc.Port, svc.PubPort, svc.LBPort}) {
+					if port == 0 {
+						continue
+					}
+
+					u, exists := used[host][port]
+					if !exists {
+						used[host][port] = user{componentName, svcName}
+						continue
+					}
+
+					conflicts = append(conflicts, PortConflict{
+						Host:           host,
+						Port:           port,
+						Component:      u.component,
+						Service:        u.service,
+						OtherComponent: componentName,
+						OtherService:   svcName,
+					})
+				}
+			}
+		}
+	}
+
+	return conflicts
 }
 
 // loadOrder init the order field of product.
-// It must loaded after loadComponents
-func (p *Product) loadOrder() error {
+// It must loaded after loadComponents.
+//
+// If the order.yaml file does not exist, the order is derived from the requires of components,
+// every component comes after the components it requires.
+// Otherwise the order.yaml file is used, and warnings are printed if it contradicts the requires.
+func (p *Product) loadOrder(graph map[string][]string) error {
+	defaultOrder := topoSort(graph)
+
 	order := make([]string, 0)
 
 	b, err := os.ReadFile(p.orderFile)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			// use default order
-			order = append(order, p.ComponentList()...)
+			order = append(order, defaultOrder...)
 			p.order = dedupSliceString(order)
 			return nil
 		}
@@ -483,8 +656,13 @@ func (p *Product) loadOrder() error {
 		}
 	}
 
-	order = append(order, p.ComponentList()...)
+	order = append(order, defaultOrder...)
 	p.order = dedupSliceString(order)
+
+	for _, msg := range checkOrderConflicts(p.order, graph) {
+		fmt.Fprintf(os.Stderr, "warn: %s, the order.yaml file (%s) contradicts the requires\n", msg, p.orderFile)
+	}
+
 	return nil
 }
 
@@ -500,3 +678,16 @@ func dedupSliceString(stringSlice []string) []string {
 	}
 	return out
 }
+
+func dedupSliceInt(intSlice []int) []int {
+	keys := make(map[int]bool)
+	out := []int{}
+
+	for _, v := range intSlice {
+		if _, exists := keys[v]; !exists {
+			keys[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,265 @@
+package product
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/bougou/sail/pkg/ansible"
+	"github.com/bougou/sail/pkg/models/cmdb"
+)
+
+func TestProduct_PortsConflicts(t *testing.T) {
+	p := NewProduct("foobar", t.TempDir())
+
+	addComponent := func(name string, enabled bool, external bool, services map[string]Service) {
+		c := NewComponent(name)
+		c.Enabled = enabled
+		c.External = external
+		c.Services = services
+		p.Components[name] = c
+	}
+
+	addComponent("api", true, false, map[string]Service{
+		"http": {Port: 8080, PubPort: 80},
+	})
+	addComponent("web", true, false, map[string]Service{
+		"http":  {Port: 80},
+		"admin": {Port: 9090, LBPort: 9090},
+	})
+	addComponent("cache", true, false, map[string]Service{
+		"default": {Port: 8080},
+	})
+	addComponent("db", false, true, map[string]Service{
+		"default": {Port: 8080},
+	})
+
+	cm := cmdb.NewCMDB()
+	for name, hosts := range map[string][]string{
+		"api":   {"10.0.0.1", "10.0.0.2"},
+		"web":   {"10.0.0.1"},
+		"cache": {"10.0.0.3"},
+		"db":    {"10.0.0.2"},
+	} {
+		g := ansible.NewGroup(name)
+		g.AddHosts(hosts...)
+		if err := cm.Inventory.AddGroup(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conflicts := p.PortsConflicts(cm)
+	if len(conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d: %v", len(conflicts), conflicts)
+	}
+
+	expected := PortConflict{Host: "10.0.0.1", Port: 80, Component: "api", Service: "http", OtherComponent: "web", OtherService: "http"}
+	if conflicts[0] != expected {
+		t.Errorf("expected conflict %s, got %s", expected, conflicts[0])
+	}
+
+	if err := p.CheckPortsConflict(cm); err == nil {
+		t.Error("expected CheckPortsConflict to return error")
+	}
+}
+
+func TestProduct_InactiveRequires(t *testing.T) {
+	productsDir := t.TempDir()
+	for name, content := range map[string]string{
+		"foobar/vars.yaml": "installDir: /opt\n",
+		"foobar/components.yaml": `web:
+  requires:
+    - component: api
+api:
+  requires:
+    - component: db
+    - service: cache-default
+db:
+  external: true
+`,
+		"foobar/components/cache.yaml": `cache:
+  requires:
+    - component: db
+  services:
+    cache-default:
+      port: 6379
+`,
+	} {
+		f := path.Join(productsDir, name)
+		if err := os.MkdirAll(path.Dir(f), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the product must be loadable, eg: the requires have no cycles
+	p := NewProduct("foobar", productsDir)
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	inactive, err := p.InactiveRequires("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"api", "cache"}
+	if strings.Join(inactive, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected inactive requires %v, got %v", expected, inactive)
+	}
+
+	if err := p.SetComponentEnabled("web", true); err != nil {
+		t.Fatal(err)
+	}
+	dependents, err := p.ActiveDependents("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(dependents, ",") != "web" {
+		t.Errorf("expected dependents [web], got %v", dependents)
+	}
+
+	// the requires of the external components are not walked
+	if err := p.SetComponentExternalEnabled("api", true); err != nil {
+		t.Fatal(err)
+	}
+	inactive, err = p.InactiveRequires("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inactive) != 0 {
+		t.Errorf("expected no inactive requires, got %v", inactive)
+	}
+
+	// the unresolvable requires of the unrelated components are ignored
+	notExist := "not-exist"
+	broken := NewComponent("broken")
+	broken.Enabled = true
+	broken.Requires = []Require{{Component: &notExist}}
+	p.Components["broken"] = broken
+	dependents, err = p.ActiveDependents("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dependents) != 0 {
+		t.Errorf("expected no dependents, got %v", dependents)
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	graph := map[string][]string{
+		"web":   {"api"},
+		"api":   {"cache", "db"},
+		"db":    {},
+		"cache": {"web"},
+	}
+
+	cycle := findCycle(graph)
+	expected := "api -> cache -> web -> api"
+	if strings.Join(cycle, " -> ") != expected {
+		t.Errorf("expected cycle %s, got %v", expected, cycle)
+	}
+
+	graph["cache"] = []string{}
+	if cycle := findCycle(graph); cycle != nil {
+		t.Errorf("expected no cycle, got %v", cycle)
+	}
+
+	order := topoSort(graph)
+	expectedOrder := []string{"cache", "db", "api", "web"}
+	if strings.Join(order, ",") != strings.Join(expectedOrder, ",") {
+		t.Errorf("expected order %v, got %v", expectedOrder, order)
+	}
+
+	msgs := checkOrderConflicts([]string{"web", "api", "cache", "db"}, graph)
+	if len(msgs) != 3 {
+		t.Errorf("expected 3 order conflicts, got %v", msgs)
+	}
+}
+
+func TestProduct_RequiresGraph(t *testing.T) {
+	p := NewProduct("foobar", t.TempDir())
+	component := func(s string) Require { return Require{Component: &s} }
+	service := func(s string) Require { return Require{Service: &s} }
+
+	for name, requires := range map[string][]Require{
+		"web": {component("api"), component("missing")},
+		"api": {service("not-provided")},
+	} {
+		c := NewComponent(name)
+		c.Requires = requires
+		p.Components[name] = c
+	}
+
+	// the unresolvable requires are ignored with warnings
+	graph, warnings := p.requiresGraph()
+	if strings.Join(graph["web"], ",") != "api" || len(graph["api"]) != 0 {
+		t.Errorf("unexpected graph %v", graph)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("expected 2 warnings, got %v", warnings)
+	}
+	if err := checkRequiresCycle(graph); err != nil {
+		t.Errorf("expected no cycle, got err: %s", err)
+	}
+
+	p.Components["api"].Requires = append(p.Components["api"].Requires, component("api"))
+	graph, _ = p.requiresGraph()
+	if err := checkRequiresCycle(graph); err == nil {
+		t.Error("expected error for the component requiring itself")
+	}
+}
+
+func TestMigration_Apply(t *testing.T) {
+	migration := Migration{
+		Version: 1,
+		Steps: []MigrationStep{
+			{MoveVar: &MoveVarStep{From: "redis_pass", Component: "redis", To: "pass"}},
+			{RenameVar: &RenameStep{From: "tz", To: "timezone"}},
+			{DeleteVar: &DeleteVarStep{Name: "obsolete"}},
+			{SetDefault: &SetDefaultStep{Name: "log_level", Value: "info"}},
+			{SetDefault: &SetDefaultStep{Name: "timezone", Value: "UTC"}},
+			{RenameComponent: &RenameStep{From: "old-web", To: "web"}},
+		},
+	}
+
+	m := map[string]interface{}{
+		"redis_pass": "secret",
+		"tz":         "Asia/Shanghai",
+		"obsolete":   true,
+		"old-web":    map[string]interface{}{"version": "1.0.0"},
+	}
+
+	if err := migration.Apply(m); err != nil {
+		t.Fatal(err)
+	}
+
+	redis := m["redis"].(map[string]interface{})
+	if redis["vars"].(map[string]interface{})["pass"] != "secret" {
+		t.Errorf("expected redis.vars.pass moved, got %v", m["redis"])
+	}
+	if m["timezone"] != "Asia/Shanghai" {
+		t.Errorf("expected timezone renamed and not overridden by default, got %v", m["timezone"])
+	}
+	if m["log_level"] != "info" {
+		t.Errorf("expected default log_level, got %v", m["log_level"])
+	}
+	for _, k := range []string{"redis_pass", "tz", "obsolete", "old-web"} {
+		if _, ok := m[k]; ok {
+			t.Errorf("expected (%s) removed", k)
+		}
+	}
+	if _, ok := m["web"]; !ok {
+		t.Errorf("expected component old-web renamed to web")
+	}
+
+	invalid := MigrationStep{
+		RenameVar: &RenameStep{From: "a", To: "b"},
+		DeleteVar: &DeleteVarStep{Name: "c"},
+	}
+	if err := invalid.validate(); err == nil {
+		t.Errorf("expected error for step with multiple actions")
+	}
+}
@@ -0,0 +1,146 @@
+package product
+
+import (
+	"fmt"
+	"sort"
+)
+
+// IsActive returns whether the component is activated (enabled:true or external:true).
+func (c *Component) IsActive() bool {
+	return c.Enabled || c.External
+}
+
+func FilterOptionActive(c *Component) bool {
+	return c.IsActive()
+}
+
+// ResolveRequire returns the name of the component which satisfies the require.
+//
+//   * component: the require is satisfied by the component.
+//   * service: the require is satisfied by the only component which declares the service.
+//   * component and service: the require is satisfied by the component, which must declare the service.
+func (p *Product) ResolveRequire(r Require) (string, error) {
+	if r.Component != nil && *r.Component != "" {
+		c, ok := p.Components[*r.Component]
+		if !ok {
+			return "", fmt.Errorf("required component (%s) does not declared by product (%s)", *r.Component, p.Name)
+		}
+		if r.Service != nil && *r.Service != "" {
+			if _, ok := c.Services[*r.Service]; !ok {
+				return "", fmt.Errorf("required component (%s) does not have service (%s)", *r.Component, *r.Service)
+			}
+		}
+		return *r.Component, nil
+	}
+
+	if r.Service != nil && *r.Service != "" {
+		found := []string{}
+		for _, componentName := range p.ComponentList() {
+			if _, ok := p.Components[componentName].Services[*r.Service]; ok {
+				found = append(found, componentName)
+			}
+		}
+		switch len(found) {
+		case 0:
+			return "", fmt.Errorf("required service (%s) is not provided by any component", *r.Service)
+		case 1:
+			return found[0], nil
+		default:
+			return "", fmt.Errorf("required service (%s) is provided by multiple components %v, specify the component", *r.Service, found)
+		}
+	}
+
+	return "", fmt.Errorf("empty require, component or service must be specified")
+}
+
+// RequiredComponents returns the names of the components directly required by the component.
+func (p *Product) RequiredComponents(name string) ([]string, error) {
+	c, ok := p.Components[name]
+	if !ok {
+		return nil, fmt.Errorf("the product does not have this component (%s)", name)
+	}
+
+	out := []string{}
+	for _, r := range c.Requires {
+		required, err := p.ResolveRequire(r)
+		if err != nil {
+			return nil, fmt.Errorf("resolve requires of component (%s) failed, err: %s", name, err)
+		}
+		if required == name {
+			return nil, fmt.Errorf("component (%s) can not require itself", name)
+		}
+		out = append(out, required)
+	}
+
+	return dedupSliceString(out), nil
+}
+
+// InactiveRequires walks the requires of the components recursively,
+// and returns the required components which are not activated.
+// The requires of the external components are not walked, they are provided outside of the product.
+// The returned components are ordered by the walk (breadth first).
+func (p *Product) InactiveRequires(names ...string) ([]string, error) {
+	visited := make(map[string]bool)
+	queue := append([]string{}, names...)
+	out := []string{}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+
+		if c, ok := p.Components[name]; ok && c.External {
+			continue
+		}
+
+		required, err := p.RequiredComponents(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range required {
+			if visited[r] {
+				continue
+			}
+			if !p.Components[r].IsActive() && !containsString(out, r) {
+				out = append(out, r)
+			}
+			queue = append(queue, r)
+		}
+	}
+
+	return out, nil
+}
+
+// ActiveDependents returns the enabled components which directly require the component.
+// The requires which can not be resolved are ignored, they must not block changing the unrelated components.
+func (p *Product) ActiveDependents(name string) ([]string, error) {
+	if !p.HasComponent(name) {
+		return nil, fmt.Errorf("the product does not have this component (%s)", name)
+	}
+
+	out := []string{}
+	for _, componentName := range p.ComponentListWithFitlerOptionsOr(FilterOptionEnabled) {
+		for _, r := range p.Components[componentName].Requires {
+			if required, err := p.ResolveRequire(r); err == nil && required == name {
+				out = append(out, componentName)
+				break
+			}
+		}
+	}
+
+	sort.Strings(out)
+	return out, nil
+}
+
+func containsString(stringSlice []string, s string) bool {
+	for _, v := range stringSlice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,23 @@ type SailOption struct {
 	PackagesDir string
 	TargetsDir  string
 
+	// LogDir is the dir to store the log files of runs, the log files of each zone
+	// are put under <LogDir>/<target>/<zone>/. Default to <zone>/logs if empty.
+	LogDir string
+	// LogRetention is the number of log files kept for each zone, 0 means keeping all.
+	LogRetention int
+	// HistoryRetention is the number of snapshots kept for each zone, 0 means keeping all.
+	HistoryRetention int
+
+	// SecretKeyFile is the file holding the key to encrypt and decrypt the secrets of zones.
+	// The SAIL_SECRET_KEY env var takes precedence over it.
+	SecretKeyFile string
+
+	// VaultPasswordFile and VaultIDs are passed through to ansible-playbook and ansible-vault,
+	// to decrypt the zone files or values encrypted by ansible-vault.
+	VaultPasswordFile string
+	VaultIDs          []string
+
 	DefaultTarget string
 	DefaultZone   string
 }
@@ -0,0 +1,102 @@
+package target
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/bougou/sail/pkg/models/product"
+)
+
+// Problem represents a problem found by the pre-flight checks of the zone.
+type Problem struct {
+	Component string
+	Message   string
+}
+
+func (p Problem) String() string {
+	if p.Component == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("component (%s): %s", p.Component, p.Message)
+}
+
+// PreflightCheck checks whether everything required by the enabled components of the zone
+// is ready before running ansible-playbook or helm, and returns all found problems.
+//
+//   * the pkg files declared by the component must exist under packages dir.
+//   * the roles of the component must exist under the roles dir of the product or the shared roles dir.
+//   * the helm chart of pod component must exist (and contain values.yaml) under the role dir.
+func (zone *Zone) PreflightCheck() []Problem {
+	problems := []Problem{}
+
+	sharedRolesDir := path.Join(zone.sailOption.ProductsDir, product.SharedRolesDir)
+
+	for _, componentName := range zone.Product.ComponentListWithFilterOptionsAnd(product.FilterOptionEnabled) {
+		component := zone.Product.Components[componentName]
+
+		for _, pkg := range component.Pkgs {
+			if pkg.File == nil || *pkg.File == "" {
+				continue
+			}
+			pkgFile := path.Join(zone.sailOption.PackagesDir, *pkg.File)
+			if _, err := os.Stat(pkgFile); err != nil {
+				problems = append(problems, Problem{componentName, fmt.Sprintf("pkg file (%s) is missing", pkgFile)})
+			}
+		}
+
+		for _, roleName := range component.GetRoles() {
+			if roleName == "" {
+				roleName = component.GetRoleName()
+			}
+			if !isDir(path.Join(zone.Product.RolesDir, roleName)) && !isDir(path.Join(sharedRolesDir, roleName)) {
+				problems = append(problems, Problem{componentName, fmt.Sprintf("role (%s) is missing under %s or %s", roleName, zone.Product.RolesDir, sharedRolesDir)})
+			}
+		}
+
+		if component.Form == product.ComponentFormPod && zone.SailHelmMode == SailHelmModeComponent {
+			// see prepareComponentChart
+			roleChartDir := path.Join(zone.Product.RolesDir, component.GetRoleName(), "helm", componentName)
+			if !isDir(roleChartDir) {
+				problems = append(problems, Problem{componentName, fmt.Sprintf("helm chart dir (%s) is missing", roleChartDir)})
+				continue
+			}
+			for _, f := range []string{"Chart.yaml", "values.yaml"} {
+				if _, err := os.Stat(path.Join(roleChartDir, f)); err != nil {
+					problems = append(problems, Problem{componentName, fmt.Sprintf("helm chart file (%s) is missing", path.Join(roleChartDir, f))})
+				}
+			}
+		}
+	}
+
+	podComponentsEnabled := zone.Product.ComponentListWithFilterOptionsAnd(product.FilterOptionEnabled, product.FilterOptionFormPod)
+	if len(podComponentsEnabled) != 0 && zone.SailHelmMode == SailHelmModeProduct {
+		// see PrepareHelmChart
+		productChartFile := path.Join(zone.Product.Dir, "Chart.yaml")
+		if _, err := os.Stat(productChartFile); err != nil {
+			problems = append(problems, Problem{"", fmt.Sprintf("helm chart file (%s) of product is missing", productChartFile)})
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Component < problems[j].Component
+	})
+
+	return problems
+}
+
+func isDir(dir string) bool {
+	stat, err := os.Stat(dir)
+	return err == nil && stat.IsDir()
+}
+
+// CheckPortsConflict computes the zone, and checks whether the ports of services
+// of the enabled components are conflicted on the hosts of the zone.
+func (zone *Zone) CheckPortsConflict() error {
+	if err := zone.Compute(); err != nil {
+		return fmt.Errorf("zone compute failed, err: %s", err)
+	}
+
+	return zone.Product.CheckPortsConflict(zone.CMDB)
+}
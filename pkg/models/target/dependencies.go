@@ -0,0 +1,67 @@
+package target
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bougou/sail/pkg/models/product"
+)
+
+// ReconfigureDependencies re-runs the reconfigure plays for all enabled components
+// listed in the `dependencies` field of the specified component.
+// It is used after the hosts of the component changed (scale-up or scale-down).
+//
+// Server components are run by ansible-playbook with `reconfigure-<componentName>` tags,
+// pod components are run by helm. The commands are run with the standard streams like RunningZone.WithIO.
+func (zone *Zone) ReconfigureDependencies(componentName string, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	component, ok := zone.Product.Components[componentName]
+	if !ok {
+		return fmt.Errorf("not found component (%s) in product", componentName)
+	}
+
+	serverComponents := make(map[string]string)
+	podComponents := make(map[string]string)
+	var ansiblePlaybookTags []string
+
+	for _, dep := range component.Dependencies {
+		depComponent, ok := zone.Product.Components[dep]
+		if !ok {
+			fmt.Fprintf(stderr, "warn: dependency (%s) of component (%s) is not a valid component, omit\n", dep, componentName)
+			continue
+		}
+		if !depComponent.Enabled {
+			continue
+		}
+
+		switch depComponent.Form {
+		case product.ComponentFormPod:
+			podComponents[dep] = ""
+		default:
+			serverComponents[dep] = ""
+			// Note: Ansible Tag for reconfigure component
+			ansiblePlaybookTags = append(ansiblePlaybookTags, "reconfigure-"+dep)
+		}
+	}
+
+	if len(serverComponents) == 0 && len(podComponents) == 0 {
+		fmt.Fprintf(stdout, "no enabled dependencies for component (%s), no need to reconfigure\n", componentName)
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "reconfigure dependencies of component (%s): %s\n", componentName, zone.Product.ComponentListWithFitlerOptionsOr(
+		product.NewFilterOptionByComponentsMap(serverComponents),
+		product.NewFilterOptionByComponentsMap(podComponents),
+	))
+
+	rz := NewRunningZone(zone, zone.Product.DefaultPlaybook())
+	rz.WithServerComponents(serverComponents)
+	rz.WithPodComponents(podComponents)
+	rz.WithAnsiblePlaybookTags(ansiblePlaybookTags)
+	rz.WithOperation("reconfigure")
+	rz.WithIO(stdin, stdout, stderr)
+	if err := rz.Run(args); err != nil {
+		return fmt.Errorf("reconfigure dependencies of component (%s) failed, err: %s", componentName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package target
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/bougou/gopkg/common"
+	"gopkg.in/yaml.v3"
+)
+
+// The sources of the fields of the effective component.
+const (
+	SourceProduct  = "product"
+	SourceZone     = "zone"
+	SourceCLI      = "cli"
+	SourceComputed = "computed"
+)
+
+// ComponentSources returns the source of each field of the effective component of the zone,
+// keyed by the dotted path of the field (eg: "services.default.port").
+// The field which is set in the zone vars file but has the same value as the product defaults
+// is considered from the product. The cliOverrides holds the paths of the fields overridden by command line.
+//
+// The zone must be loaded and computed.
+func (zone *Zone) ComponentSources(componentName string, cliOverrides []string) (map[string]string, error) {
+	c, ok := zone.Product.Components[componentName]
+	if !ok {
+		return nil, fmt.Errorf("not found component (%s) in product", componentName)
+	}
+
+	effective, err := toFlatMap(c)
+	if err != nil {
+		return nil, fmt.Errorf("flatten effective component failed, err: %s", err)
+	}
+
+	defaults := map[string]interface{}{}
+	if dc, ok := zone.Product.DefaultComponents[componentName]; ok {
+		defaults, err = toFlatMap(dc)
+		if err != nil {
+			return nil, fmt.Errorf("flatten default component failed, err: %s", err)
+		}
+	}
+
+	b, err := zone.readZoneFile(zone.VarsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read zone vars file failed, err: %s", err)
+	}
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("unmarshal zone vars file failed, err: %s", err)
+	}
+	zoneVars := map[string]interface{}{}
+	flatten("", m[componentName], zoneVars)
+
+	cli := make(map[string]bool)
+	for _, p := range cliOverrides {
+		cli[p] = true
+	}
+
+	sources := make(map[string]string)
+	for p := range effective {
+		zoneValue, inZone := zoneVars[p]
+		defaultValue, inDefaults := defaults[p]
+
+		switch {
+		case cli[p]:
+			sources[p] = SourceCLI
+		case p == "computed" || strings.HasPrefix(p, "computed."):
+			sources[p] = SourceComputed
+		case inZone && (!inDefaults || !reflect.DeepEqual(zoneValue, defaultValue)):
+			sources[p] = SourceZone
+		default:
+			sources[p] = SourceProduct
+		}
+	}
+
+	return sources, nil
+}
+
+// AnnotateSources encodes v to yaml, and comments each field with its source.
+func AnnotateSources(v interface{}, sources map[string]string) (*yaml.Node, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(v); err != nil {
+		return nil, err
+	}
+	annotate("", node, sources)
+	return node, nil
+}
+
+func annotate(prefix string, node *yaml.Node, sources map[string]string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		p := joinPath(prefix, k.Value)
+
+		if source, ok := sources[p]; ok {
+			// the comment of the key is misplaced for the flow style value
+			if v.Kind == yaml.ScalarNode || len(v.Content) == 0 {
+				v.LineComment = source
+			} else {
+				k.LineComment = source
+			}
+			continue
+		}
+		annotate(p, v, sources)
+	}
+}
+
+// toFlatMap converts v to a map keyed by the dotted paths of the leaf fields.
+// The lists and empty maps are treated as leaf fields.
+func toFlatMap(v interface{}) (map[string]interface{}, error) {
+	b, err := common.Encode("yaml", v)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{})
+	flatten("", m, out)
+	return out, nil
+}
+
+func flatten(prefix string, v interface{}, out map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok || (len(m) == 0 && prefix != "") {
+		if prefix != "" {
+			out[prefix] = v
+		}
+		return
+	}
+
+	for k, vv := range m {
+		flatten(joinPath(prefix, k), vv, out)
+	}
+}
+
+func joinPath(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
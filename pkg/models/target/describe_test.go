@@ -0,0 +1,76 @@
+package target
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestZone_ComponentSources(t *testing.T) {
+	vars := `foobar-api:
+  version: v1.0.0
+  vars:
+    log_level: debug
+  services:
+    default:
+      port: 8080
+`
+	hosts := "foobar-api:\n  hosts:\n    10.0.0.1: {}\n"
+	zone := newTestZone(t, newTestSailOption(t), "t1", "z1", vars, hosts)
+
+	// the version overridden by command line
+	if err := zone.SetComponentVersion("foobar-api", "v1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zone.Compute(); err != nil {
+		t.Fatal(err)
+	}
+
+	sources, err := zone.ComponentSources("foobar-api", []string{"version"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"version":                 SourceCLI,
+		"vars.log_level":          SourceZone,
+		"services.default.port":   SourceProduct,
+		"services.default.scheme": SourceProduct,
+		"enabled":                 SourceProduct,
+	}
+	for p, source := range expected {
+		if sources[p] != source {
+			t.Errorf("expected source (%s) for field (%s), got (%s)", source, p, sources[p])
+		}
+	}
+
+	computed := 0
+	for p, source := range sources {
+		if strings.HasPrefix(p, "computed.") {
+			computed++
+			if source != SourceComputed {
+				t.Errorf("expected source (%s) for field (%s), got (%s)", SourceComputed, p, source)
+			}
+		}
+	}
+	if computed == 0 {
+		t.Errorf("expected computed fields in sources, got %v", sources)
+	}
+
+	node, err := AnnotateSources(zone.Product.Components["foobar-api"], sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := yaml.Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "log_level: debug # zone") {
+		t.Errorf("expected the source comment of log_level, got:\n%s", b)
+	}
+
+	if _, err := zone.ComponentSources("not-exist", nil); err == nil {
+		t.Error("expected error for not existed component")
+	}
+}
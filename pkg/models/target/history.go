@@ -0,0 +1,276 @@
+package target
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/gopkg/copy"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SnapshotMetaFile is the file in the snapshot dir which records the information of the snapshot.
+	SnapshotMetaFile = "snapshot.yaml"
+
+	// DefaultHistoryRetention is the default number of snapshots kept for each zone.
+	DefaultHistoryRetention = 30
+
+	snapshotIDLayout = "20060102-150405"
+)
+
+// Snapshot is a copy of the zone files taken before they are overwritten.
+// The snapshots are saved under <zone>/.history/<id>/.
+type Snapshot struct {
+	ID        string    `json:"id" yaml:"id"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+	// the sail command line which caused the zone files to be overwritten
+	Command string `json:"command" yaml:"command"`
+	// the base names of the saved zone files
+	Files []string `json:"files" yaml:"files"`
+}
+
+// snapshotFiles returns the zone files which are saved into snapshots.
+func (zone *Zone) snapshotFiles() []string {
+	return []string{
+		zone.VarsFile,
+		zone.HostsFile,
+		zone.PlatformsFile,
+		zone.ComputedFile,
+	}
+}
+
+// Snapshot copies the current zone files into a new snapshot dir, and prunes the oldest snapshots.
+// It returns nil snapshot if none of the zone files exists (eg: the zone is newly created).
+func (zone *Zone) Snapshot() (*Snapshot, error) {
+	snapshot, err := zone.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := zone.pruneSnapshots(); err != nil {
+		fmt.Fprintf(os.Stderr, "warn: prune old snapshots failed, err: %s\n", err)
+	}
+
+	return snapshot, nil
+}
+
+// snapshotBeforeWrite takes a snapshot before the zone file is overwritten with the content.
+// At most one snapshot is taken for the zone in a command, which holds the zone files before the command.
+// The changes of the playbook file and the derived inventory file, and the changes
+// of the computed file which only touch the vars of the other zones (targetvars) are ignored.
+func (zone *Zone) snapshotBeforeWrite(name string, old []byte, content []byte) error {
+	if zone.snapshotted || bytes.Equal(old, content) {
+		return nil
+	}
+
+	switch name {
+	case zone.VarsFile, zone.HostsFile, zone.PlatformsFile:
+	case zone.ComputedFile:
+		if !computedChanged(old, content) {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if _, err := zone.Snapshot(); err != nil {
+		return fmt.Errorf("snapshot zone failed, err: %s", err)
+	}
+	zone.snapshotted = true
+	return nil
+}
+
+// computedChanged returns whether the computed file is changed except for the targetvars.
+func computedChanged(old []byte, content []byte) bool {
+	var a, b map[string]interface{}
+	if err := yaml.Unmarshal(old, &a); err != nil {
+		return true
+	}
+	if err := yaml.Unmarshal(content, &b); err != nil {
+		return true
+	}
+	delete(a, "targetvars")
+	delete(b, "targetvars")
+	return !reflect.DeepEqual(a, b)
+}
+
+// pruneSnapshots removes the oldest snapshots of the zone to keep at most sailOption.HistoryRetention snapshots.
+// Zero or negative retention means keeping all snapshots.
+func (zone *Zone) pruneSnapshots() error {
+	retention := zone.sailOption.HistoryRetention
+	if retention <= 0 {
+		return nil
+	}
+
+	snapshots, err := zone.Histories()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) <= retention {
+		return nil
+	}
+
+	for _, snapshot := range snapshots[:len(snapshots)-retention] {
+		if err := os.RemoveAll(path.Join(zone.HistoryDir, snapshot.ID)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (zone *Zone) snapshot() (*Snapshot, error) {
+	existed := []string{}
+	for _, f := range zone.snapshotFiles() {
+		if _, err := os.Stat(f); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("access file (%s) failed, err: %s", f, err)
+		}
+		existed = append(existed, f)
+	}
+	if len(existed) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	id := now.Format(snapshotIDLayout)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path.Join(zone.HistoryDir, id)); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", now.Format(snapshotIDLayout), i)
+	}
+
+	snapshotDir := path.Join(zone.HistoryDir, id)
+	if err := os.MkdirAll(snapshotDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("make snapshot dir failed, err: %s", err)
+	}
+
+	snapshot := &Snapshot{
+		ID:        id,
+		CreatedAt: now,
+		Command:   strings.Join(os.Args, " "),
+		Files:     []string{},
+	}
+	for _, f := range existed {
+		if err := copy.CopyFile(f, path.Join(snapshotDir, path.Base(f))); err != nil {
+			return nil, fmt.Errorf("copy file (%s) failed, err: %s", f, err)
+		}
+		snapshot.Files = append(snapshot.Files, path.Base(f))
+	}
+
+	b, err := common.Encode("yaml", snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("encode snapshot failed, err: %s", err)
+	}
+	if err := os.WriteFile(path.Join(snapshotDir, SnapshotMetaFile), b, 0644); err != nil {
+		return nil, fmt.Errorf("write snapshot file failed, err: %s", err)
+	}
+
+	return snapshot, nil
+}
+
+// Histories returns all snapshots of the zone, ordered from oldest to newest.
+func (zone *Zone) Histories() ([]*Snapshot, error) {
+	entries, err := os.ReadDir(zone.HistoryDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*Snapshot{}, nil
+		}
+		return nil, fmt.Errorf("read history dir failed, err: %s", err)
+	}
+
+	snapshots := []*Snapshot{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshot, err := zone.GetSnapshot(entry.Name())
+		if err != nil {
+			// not a snapshot dir, ignore
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].ID < snapshots[j].ID
+		}
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+
+	return snapshots, nil
+}
+
+// GetSnapshot returns the snapshot of the id.
+func (zone *Zone) GetSnapshot(id string) (*Snapshot, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fmt.Errorf("invalid snapshot id (%s)", id)
+	}
+
+	metaFile := path.Join(zone.HistoryDir, id, SnapshotMetaFile)
+	b, err := os.ReadFile(metaFile)
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot (%s) failed, err: %s", id, err)
+	}
+
+	snapshot := &Snapshot{}
+	if err := yaml.Unmarshal(b, snapshot); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshot (%s) failed, err: %s", id, err)
+	}
+	snapshot.ID = id
+
+	return snapshot, nil
+}
+
+// Rollback restores the zone files from the snapshot of the id.
+// The current zone files are saved into a new snapshot before restoring, so the rollback itself can be undone.
+// The zone files which do not exist in the snapshot are removed.
+func (zone *Zone) Rollback(id string) (*Snapshot, error) {
+	snapshot, err := zone.GetSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// prune after restoring, the snapshot to restore may be the oldest one
+	current, err := zone.snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("snapshot current zone files failed, err: %s", err)
+	}
+
+	saved := make(map[string]bool)
+	for _, f := range snapshot.Files {
+		saved[f] = true
+	}
+
+	snapshotDir := path.Join(zone.HistoryDir, id)
+	for _, f := range zone.snapshotFiles() {
+		if !saved[path.Base(f)] {
+			if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("remove file (%s) failed, err: %s", f, err)
+			}
+			continue
+		}
+
+		if err := copy.CopyFile(path.Join(snapshotDir, path.Base(f)), f); err != nil {
+			return nil, fmt.Errorf("restore file (%s) failed, err: %s", f, err)
+		}
+	}
+
+	if err := zone.pruneSnapshots(); err != nil {
+		fmt.Fprintf(os.Stderr, "warn: prune old snapshots failed, err: %s\n", err)
+	}
+
+	return current, nil
+}
@@ -0,0 +1,104 @@
+package target
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestZone_Snapshot(t *testing.T) {
+	sailOption := newTestSailOption(t)
+	sailOption.HistoryRetention = 3
+	hosts := "foobar-api:\n  hosts:\n    10.0.0.1: {}\n"
+
+	countSnapshots := func(zone *Zone) int {
+		snapshots, err := zone.Histories()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(snapshots)
+	}
+	loadZone := func(zoneName string) *Zone {
+		zone := NewZone(sailOption, "t1", zoneName)
+		if err := zone.LoadConf(); err != nil {
+			t.Fatal(err)
+		}
+		return zone
+	}
+
+	// at most one snapshot is taken by a command
+	z1 := newTestZone(t, sailOption, "t1", "z1", "", hosts)
+	if err := z1.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	z1.Product.Vars["installDir"] = "/usr/local"
+	if err := z1.RenderVars(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countSnapshots(z1); n != 1 {
+		t.Errorf("expected 1 snapshot, got %d", n)
+	}
+
+	// the writers other than Dump are snapshotted too
+	z1 = loadZone("z1")
+	z1.Product.Vars["installDir"] = "/srv"
+	if err := z1.RenderVars(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countSnapshots(z1); n != 2 {
+		t.Errorf("expected 2 snapshots after RenderVars, got %d", n)
+	}
+
+	// the changes of the vars of the other zones are ignored
+	z2 := newTestZone(t, sailOption, "t1", "z2", "", hosts)
+	if err := z2.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	old, err := os.ReadFile(z1.ComputedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgetTargetVars()
+	z1 = loadZone("z1")
+	if err := z1.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(z1.ComputedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(old, b) {
+		t.Fatal("expected the targetvars in computed file changed")
+	}
+	if n := countSnapshots(z1); n != 2 {
+		t.Errorf("expected no snapshot for the changes of targetvars, got %d snapshots", n)
+	}
+
+	// the oldest snapshots are pruned
+	for _, dir := range []string{"/a", "/b", "/c"} {
+		z1 = loadZone("z1")
+		z1.Product.Vars["installDir"] = dir
+		if err := z1.RenderVars(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshots, err := z1.Histories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("expected 3 snapshots kept, got %d", len(snapshots))
+	}
+
+	// the rollback to the oldest snapshot (taken before installDir changed to /a) is not pruned before restored
+	if _, err := z1.Rollback(snapshots[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := countSnapshots(z1); n != 3 {
+		t.Errorf("expected 3 snapshots kept after rollback, got %d", n)
+	}
+	z1 = loadZone("z1")
+	if dir := z1.Product.Vars["installDir"]; dir != "/srv" {
+		t.Errorf("expected installDir /srv after rollback, got %v", dir)
+	}
+}
@@ -0,0 +1,358 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bougou/sail/pkg/ansible"
+	"gopkg.in/yaml.v3"
+)
+
+// SailMetaVarInventorySources is the variable of the "all" group in hosts.yaml declaring the additional inventory sources.
+const SailMetaVarInventorySources = "_sail_inventory_sources"
+
+const (
+	InventorySourceScript = "script"
+	InventorySourceCSV    = "csv"
+	InventorySourceINI    = "ini"
+	InventorySourceHTTP   = "http"
+
+	defaultInventorySourceTimeout = 30
+)
+
+// InventorySource is an additional inventory source of the zone, the groups got from the sources
+// are merged into (and override the same name groups of) the inventory in hosts.yaml.
+//
+//	all:
+//	  vars:
+//	    _sail_inventory_sources:
+//	      - type: script # an executable emitting ansible JSON inventory with `--list`
+//	        path: cmdb.sh
+//	      - type: csv # columns: host,groups,<hostvar>...
+//	        path: hosts.csv
+//	      - type: ini # ansible INI inventory
+//	        path: hosts.ini
+//	      - type: http # an endpoint returning ansible JSON inventory
+//	        url: https://cmdb.example.com/inventory?zone=z1
+//	        headers:
+//	          Authorization: Bearer ${CMDB_TOKEN}
+//
+// The relative paths are relative to the zone dir, the env vars in url and headers are expanded.
+type InventorySource struct {
+	Type    string            `yaml:"type"`
+	Path    string            `yaml:"path,omitempty"`
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Timeout is the timeout seconds of running the script or requesting the endpoint, default 30.
+	Timeout int `yaml:"timeout,omitempty"`
+}
+
+func (s *InventorySource) Validate() error {
+	switch s.Type {
+	case InventorySourceScript, InventorySourceCSV, InventorySourceINI:
+		if s.Path == "" {
+			return fmt.Errorf("path is required for (%s) inventory source", s.Type)
+		}
+	case InventorySourceHTTP:
+		if s.URL == "" {
+			return fmt.Errorf("url is required for (%s) inventory source", s.Type)
+		}
+	default:
+		return fmt.Errorf("unknown inventory source type (%s), valid types: script, csv, ini, http", s.Type)
+	}
+
+	if s.Timeout < 0 {
+		return fmt.Errorf("invalid timeout (%d)", s.Timeout)
+	}
+	return nil
+}
+
+func (s *InventorySource) String() string {
+	if s.Type == InventorySourceHTTP {
+		return s.Type + " " + s.URL
+	}
+	return s.Type + " " + s.Path
+}
+
+func (s *InventorySource) timeout() time.Duration {
+	if s.Timeout == 0 {
+		return defaultInventorySourceTimeout * time.Second
+	}
+	return time.Duration(s.Timeout) * time.Second
+}
+
+// ParseInventorySources returns the inventory sources declared in the "all" group of the inventory.
+func ParseInventorySources(i *ansible.Inventory) ([]*InventorySource, error) {
+	all, err := i.GetGroup(ansible.AllGroupName)
+	if err != nil || all.Vars == nil {
+		return nil, nil
+	}
+	v, ok := (*all.Vars)[SailMetaVarInventorySources]
+	if !ok || v == nil {
+		return nil, nil
+	}
+
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := []*InventorySource{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&sources); err != nil {
+		return nil, fmt.Errorf("parse (%s) failed, err: %s", SailMetaVarInventorySources, err)
+	}
+
+	for n, s := range sources {
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid inventory source #%d, err: %s", n+1, err)
+		}
+	}
+
+	return sources, nil
+}
+
+// HasInventorySources returns whether the zone declares inventory sources in hosts.yaml.
+func (zone *Zone) HasInventorySources() bool {
+	return len(zone.inventorySources) != 0
+}
+
+// AnsibleInventoryFile returns the inventory file passed to ansible-playbook.
+func (zone *Zone) AnsibleInventoryFile() string {
+	if zone.HasInventorySources() {
+		return zone.InventoryFile
+	}
+	return zone.HostsFile
+}
+
+// LoadInventorySources merges the groups got from the inventory sources into the inventory of the zone.
+// The sources are not loaded by LoadConf, because the scripts and the endpoints are only needed
+// by the commands which use the hosts of the zone (eg: apply, check, describe),
+// they are not needed for reading the zone, eg: the vars of the other zones.
+// The outputs of the scripts and the endpoints are cached, they are run at most once per process for each zone.
+func (zone *Zone) LoadInventorySources() error {
+	if !zone.HasInventorySources() || zone.sourcesInventory != nil {
+		return nil
+	}
+
+	if err := zone.loadInventorySources(zone.inventorySources); err != nil {
+		return fmt.Errorf("load inventory sources failed, err: %s", err)
+	}
+	return nil
+}
+
+// loadInventorySources merges the groups got from the inventory sources into the inventory of the zone.
+func (zone *Zone) loadInventorySources(sources []*InventorySource) error {
+	finders := []ansible.InventoryFinderFunc{}
+	for _, s := range sources {
+		finders = append(finders, zone.inventoryFinder(s))
+	}
+
+	i := ansible.NewInventory()
+	if err := i.MergeWithFuncs(finders...); err != nil {
+		return err
+	}
+	// the common vars are set in hosts.yaml
+	i.RemoveGroup(ansible.AllGroupName)
+
+	// keep the groups in hosts.yaml which are overridden, they are written back to hosts.yaml
+	overridden := make(map[string]*ansible.Group)
+	for groupName := range i.GroupsMap {
+		if group, err := zone.CMDB.Inventory.GetGroup(groupName); err == nil {
+			overridden[groupName] = group
+		}
+	}
+
+	if err := zone.CMDB.Inventory.Merge(i); err != nil {
+		return err
+	}
+
+	zone.sourcesInventory = i
+	zone.overriddenGroups = overridden
+	return nil
+}
+
+// FreezeInventorySources removes the inventory sources declaration,
+// so the groups got from the sources are written into hosts.yaml by the next Dump.
+func (zone *Zone) FreezeInventorySources() error {
+	if !zone.HasInventorySources() {
+		return errors.New("the zone has no inventory sources")
+	}
+
+	if zone.sourcesInventory == nil {
+		return errors.New("the inventory sources of the zone are not loaded")
+	}
+
+	all, err := zone.CMDB.Inventory.GetGroup(ansible.AllGroupName)
+	if err == nil && all.Vars != nil {
+		all.RemoveVar(SailMetaVarInventorySources)
+	}
+
+	zone.inventorySources = nil
+	zone.sourcesInventory = nil
+	zone.overriddenGroups = nil
+	return nil
+}
+
+// staticInventory returns the inventory written to hosts.yaml, the groups got from the inventory sources are excluded.
+func (zone *Zone) staticInventory() *ansible.Inventory {
+	if zone.sourcesInventory == nil {
+		return zone.CMDB.Inventory
+	}
+
+	i := ansible.NewInventory()
+	for groupName, group := range zone.CMDB.Inventory.GroupsMap {
+		if zone.sourcesInventory.HasGroup(groupName) {
+			if overridden, ok := zone.overriddenGroups[groupName]; ok {
+				i.GroupsMap[groupName] = overridden
+			}
+			continue
+		}
+		i.GroupsMap[groupName] = group
+	}
+	return i
+}
+
+func (zone *Zone) inventoryFinder(s *InventorySource) ansible.InventoryFinderFunc {
+	return func() (*ansible.Inventory, error) {
+		var i *ansible.Inventory
+		var b []byte
+		var err error
+
+		switch s.Type {
+		case InventorySourceScript:
+			b, err = cachedInventorySourceOutput(zone.ZoneDir+" "+s.String(), func() ([]byte, error) {
+				return zone.runInventoryScript(s)
+			})
+			if err == nil {
+				i, err = ansible.NewInventoryFromJSON(b)
+			}
+		case InventorySourceCSV, InventorySourceINI:
+			b, err = os.ReadFile(zone.inventorySourcePath(s))
+			if err != nil {
+				break
+			}
+			if s.Type == InventorySourceCSV {
+				i, err = ansible.NewInventoryFromCSV(b)
+			} else {
+				i, err = ansible.NewInventoryFromINI(b)
+			}
+		case InventorySourceHTTP:
+			b, err = cachedInventorySourceOutput(zone.ZoneDir+" "+s.String(), func() ([]byte, error) {
+				return requestInventoryEndpoint(s)
+			})
+			if err == nil {
+				i, err = ansible.NewInventoryFromJSON(b)
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("inventory source (%s): %s", s, err)
+		}
+		return i, nil
+	}
+}
+
+// inventorySourceOutputs caches the outputs of the inventory scripts and endpoints in the process.
+var inventorySourceOutputs = struct {
+	sync.Mutex
+	m map[string]*inventorySourceOutput
+}{m: make(map[string]*inventorySourceOutput)}
+
+type inventorySourceOutput struct {
+	once sync.Once
+	b    []byte
+	err  error
+}
+
+// cachedInventorySourceOutput returns the cached output of the key, fetch is called only once for each key,
+// even if the zones are run in parallel.
+func cachedInventorySourceOutput(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	inventorySourceOutputs.Lock()
+	o, ok := inventorySourceOutputs.m[key]
+	if !ok {
+		o = &inventorySourceOutput{}
+		inventorySourceOutputs.m[key] = o
+	}
+	inventorySourceOutputs.Unlock()
+
+	o.once.Do(func() {
+		o.b, o.err = fetch()
+	})
+	return o.b, o.err
+}
+
+func (zone *Zone) inventorySourcePath(s *InventorySource) string {
+	if path.IsAbs(s.Path) {
+		return s.Path
+	}
+	return path.Join(zone.ZoneDir, s.Path)
+}
+
+// runInventoryScript returns the output of running the script with `--list`.
+func (zone *Zone) runInventoryScript(s *InventorySource) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, zone.inventorySourcePath(s), "--list")
+	cmd.Dir = zone.ZoneDir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("run script failed, err: %s, %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// maxInventoryErrorBody is the max length of the response body included in the error of the endpoint.
+const maxInventoryErrorBody = 512
+
+// requestInventoryEndpoint returns the response body of the endpoint.
+func requestInventoryEndpoint(s *InventorySource) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, os.ExpandEnv(s.URL), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range s.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// only a short piece of the body is read, it is enough to know the reason of the error
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, maxInventoryErrorBody+1))
+		body := strings.TrimSpace(string(b))
+		if len(b) > maxInventoryErrorBody {
+			body = strings.TrimSpace(string(b[:maxInventoryErrorBody])) + " ..."
+		}
+		return nil, fmt.Errorf("unexpected status code %d, body: %s", resp.StatusCode, body)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
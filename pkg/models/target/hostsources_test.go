@@ -0,0 +1,38 @@
+package target
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestInventoryEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("invalid token " + strings.Repeat("x", 2048)))
+			return
+		}
+		w.Write([]byte(`{"foobar-api": {"hosts": ["10.0.0.1"]}}`))
+	}))
+	defer server.Close()
+
+	s := &InventorySource{Type: InventorySourceHTTP, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer t0ken"}}
+	b, err := requestInventoryEndpoint(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "10.0.0.1") {
+		t.Errorf("unexpected body %s", string(b))
+	}
+
+	s.Headers = nil
+	_, err = requestInventoryEndpoint(s)
+	if err == nil {
+		t.Fatal("expected error for the status code 401")
+	}
+	if !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "invalid token") || len(err.Error()) > maxInventoryErrorBody+100 {
+		t.Errorf("expected the status code and the truncated body in the error, got %s", err)
+	}
+}
@@ -0,0 +1,159 @@
+package target
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	labelKeyRegex   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.\-/]*[A-Za-z0-9])?$`)
+	labelValueRegex = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9_.\-]*[A-Za-z0-9])?)?$`)
+)
+
+// Labels are the key/value pairs attached to the zone, used to select zones.
+type Labels map[string]string
+
+// String returns the labels like "region=east,tier=canary", sorted by keys.
+func (l Labels) String() string {
+	keys := []string{}
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+l[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ValidateLabel returns error if the label key or value is not valid.
+func ValidateLabel(key string, value string) error {
+	if !labelKeyRegex.MatchString(key) {
+		return fmt.Errorf("invalid label key (%s), must consist of alphanumeric characters, '-', '_', '.' or '/', and start and end with an alphanumeric character", key)
+	}
+	if !labelValueRegex.MatchString(value) {
+		return fmt.Errorf("invalid label value (%s) for key (%s), must be empty or consist of alphanumeric characters, '-', '_' or '.', and start and end with an alphanumeric character", value, key)
+	}
+	return nil
+}
+
+// SetLabels adds, updates or removes the labels of the zone.
+// The labels in removed are removed after the labels in labels are set.
+func (zone *Zone) SetLabels(labels Labels, removed []string) error {
+	current := Labels{}
+	for k, v := range zone.SailLabels {
+		current[k] = v
+	}
+
+	for k, v := range labels {
+		if err := ValidateLabel(k, v); err != nil {
+			return err
+		}
+		current[k] = v
+	}
+	for _, k := range removed {
+		delete(current, k)
+	}
+
+	zone.SailLabels = current
+	if zone.Product != nil {
+		zone.Product.Vars[SailMetaVarLabels] = map[string]string(current)
+	}
+
+	return nil
+}
+
+// Selector selects zones by their labels.
+// The requirements are separated by comma, and all of them must be satisfied:
+//
+//	key=value, key==value  the zone has the label with the value
+//	key!=value             the zone does not have the label with the value
+//	key                    the zone has the label
+//	!key                   the zone does not have the label
+type Selector []requirement
+
+type requirement struct {
+	key      string
+	operator string
+	value    string
+}
+
+const (
+	selectorOpEquals    = "="
+	selectorOpNotEquals = "!="
+	selectorOpExists    = "exists"
+	selectorOpNotExists = "!"
+)
+
+// ParseSelector parses the selector string. An empty string returns a selector which matches everything.
+func ParseSelector(s string) (Selector, error) {
+	selector := Selector{}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var r requirement
+		switch {
+		case strings.Contains(part, "!="):
+			kv := strings.SplitN(part, "!=", 2)
+			r = requirement{key: kv[0], operator: selectorOpNotEquals, value: kv[1]}
+		case strings.Contains(part, "=="):
+			kv := strings.SplitN(part, "==", 2)
+			r = requirement{key: kv[0], operator: selectorOpEquals, value: kv[1]}
+		case strings.Contains(part, "="):
+			kv := strings.SplitN(part, "=", 2)
+			r = requirement{key: kv[0], operator: selectorOpEquals, value: kv[1]}
+		case strings.HasPrefix(part, "!"):
+			r = requirement{key: strings.TrimPrefix(part, "!"), operator: selectorOpNotExists}
+		default:
+			r = requirement{key: part, operator: selectorOpExists}
+		}
+
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if err := ValidateLabel(r.key, r.value); err != nil {
+			return nil, fmt.Errorf("invalid selector (%s), %s", part, err)
+		}
+		selector = append(selector, r)
+	}
+
+	return selector, nil
+}
+
+// Matches returns whether the labels satisfy all requirements of the selector.
+func (selector Selector) Matches(labels Labels) bool {
+	for _, r := range selector {
+		v, ok := labels[r.key]
+		switch r.operator {
+		case selectorOpEquals:
+			if !ok || v != r.value {
+				return false
+			}
+		case selectorOpNotEquals:
+			if ok && v == r.value {
+				return false
+			}
+		case selectorOpExists:
+			if !ok {
+				return false
+			}
+		case selectorOpNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Empty returns whether the selector has no requirements.
+func (selector Selector) Empty() bool {
+	return len(selector) == 0
+}
@@ -0,0 +1,38 @@
+package target
+
+import (
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+	labels := Labels{"region": "east", "tier": "canary"}
+
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"tier=canary", true},
+		{"tier==canary,region=east", true},
+		{"tier=canary,region=west", false},
+		{"tier!=canary", false},
+		{"tier!=prod", true},
+		{"region", true},
+		{"!region", false},
+		{"!owner", true},
+	}
+
+	for _, tt := range tests {
+		selector, err := ParseSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("parse selector (%s) failed, err: %s", tt.selector, err)
+		}
+		if got := selector.Matches(labels); got != tt.matches {
+			t.Errorf("selector (%s): expected matches %v, got %v", tt.selector, tt.matches, got)
+		}
+	}
+
+	if _, err := ParseSelector("tier=a b"); err == nil {
+		t.Errorf("expected error for invalid selector")
+	}
+}
@@ -0,0 +1,209 @@
+package target
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultLogRetention is the default number of log files kept for each zone.
+	DefaultLogRetention = 30
+
+	redactedMask = "******"
+)
+
+// secretKeyRegex matches the names of the variables whose values are treated as secrets.
+var secretKeyRegex = regexp.MustCompile(`(?i)(pass|passwd|password|secret|token|apikey|api_key|private_key|credential)`)
+
+// secretAssignRegex matches the secrets appeared like `password=xxx` or `token: xxx` in the output.
+var secretAssignRegex = regexp.MustCompile(`(?i)((?:pass|passwd|password|secret|token|apikey|api_key|private_key|credential)\w*["']?\s*[=:]\s*["']?)([^\s"',}]+)`)
+
+// OpenRunLog creates the log file for the run in the log dir of the zone.
+// The old log files exceeding the retention are removed by PruneLogs.
+// The returned writer redacts the secrets of the zone, it MUST be closed after the run.
+func (zone *Zone) OpenRunLog(runID string) (io.WriteCloser, string, error) {
+	if err := os.MkdirAll(zone.LogDir, 0750); err != nil {
+		return nil, "", fmt.Errorf("make log dir failed, err: %s", err)
+	}
+
+	logFileName := path.Join(zone.LogDir, runID+".log")
+	f, err := os.OpenFile(logFileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, "", fmt.Errorf("can not create log file: %s, err: %s", logFileName, err)
+	}
+
+	return newRedactWriter(f, zone.SecretValues()), logFileName, nil
+}
+
+// PruneLogs removes the oldest log files of the zone to keep at most sailOption.LogRetention files.
+// Zero or negative retention means keeping all log files.
+func (zone *Zone) PruneLogs() error {
+	retention := zone.sailOption.LogRetention
+	if retention <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(zone.LogDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	logFiles := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		logFiles = append(logFiles, entry.Name())
+	}
+	if len(logFiles) <= retention {
+		return nil
+	}
+
+	// the log file names start with timestamp, so they are sorted by time
+	sort.Strings(logFiles)
+	for _, name := range logFiles[:len(logFiles)-retention] {
+		if err := os.Remove(path.Join(zone.LogDir, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SecretValues returns the values of the zone variables which look like secrets,
+// like the variables whose names contain "password" or "token", and the decrypted secrets of the zone.
+func (zone *Zone) SecretValues() []string {
+	found := make(map[string]bool)
+	for _, v := range zone.secrets {
+		if v != "" {
+			found[v] = true
+		}
+	}
+
+	if zone.Product != nil {
+		collectSecretValues(zone.Product.Vars, found)
+		for _, c := range zone.Product.Components {
+			collectSecretValues(c.Vars, found)
+		}
+	}
+
+	out := []string{}
+	for v := range found {
+		out = append(out, v)
+	}
+	// replace longer secrets first, in case one secret contains another
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i]) != len(out[j]) {
+			return len(out[i]) > len(out[j])
+		}
+		return out[i] < out[j]
+	})
+
+	return out
+}
+
+func collectSecretValues(m map[string]interface{}, found map[string]bool) {
+	for k, v := range m {
+		switch vv := v.(type) {
+		case string:
+			// too short values are likely to be placeholders, and redacting them would mess up the log
+			if secretKeyRegex.MatchString(k) && len(vv) >= 4 {
+				found[vv] = true
+			}
+		case map[string]interface{}:
+			collectSecretValues(vv, found)
+		}
+	}
+}
+
+// MaskSecrets returns the writer which masks the secrets of the zone written to w,
+// that is, the decrypted secrets, the values of the password-like variables, and the values appeared like `password=xxx`.
+// All the output of sail which may contain the values of the zone variables MUST be written through it.
+// The returned writer MUST be closed to flush the buffered content, w itself is not closed.
+func (zone *Zone) MaskSecrets(w io.Writer) io.WriteCloser {
+	return NewMaskWriter(w, zone.SecretValues())
+}
+
+// NewMaskWriter is like Zone.MaskSecrets, but masks the specified secrets, eg: when no zone is loaded.
+func NewMaskWriter(w io.Writer, secrets []string) io.WriteCloser {
+	return newRedactWriter(nopWriteCloser{w}, secrets)
+}
+
+// MaskSecretsString returns s with the secrets of the zone masked like MaskSecrets.
+func (zone *Zone) MaskSecretsString(s string) string {
+	return newRedactWriter(nil, zone.SecretValues()).redact(s)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// redactWriter masks the secrets in the written content line by line.
+// It is safe for concurrent use, eg: shared by the stdout and stderr of a command.
+type redactWriter struct {
+	mu      sync.Mutex
+	w       io.WriteCloser
+	secrets []string
+	buf     []byte
+}
+
+func newRedactWriter(w io.WriteCloser, secrets []string) *redactWriter {
+	return &redactWriter{
+		w:       w,
+		secrets: secrets,
+	}
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf = append(r.buf, p...)
+
+	i := bytes.LastIndexByte(r.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+
+	if _, err := r.w.Write([]byte(r.redact(string(r.buf[:i+1])))); err != nil {
+		return 0, err
+	}
+	r.buf = append(r.buf[:0], r.buf[i+1:]...)
+
+	return len(p), nil
+}
+
+// Close writes the remaining buffered content and closes the underlying writer.
+func (r *redactWriter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.buf) != 0 {
+		if _, err := r.w.Write([]byte(r.redact(string(r.buf)))); err != nil {
+			r.w.Close()
+			return err
+		}
+		r.buf = nil
+	}
+	return r.w.Close()
+}
+
+func (r *redactWriter) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedMask)
+	}
+	return secretAssignRegex.ReplaceAllString(s, "${1}"+redactedMask)
+}
@@ -0,0 +1,57 @@
+package target
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestRedactWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := newRedactWriter(nopCloser{buf}, []string{"s3cr3tpass"})
+
+	// the secret is split across writes
+	w.Write([]byte("connect with s3cr3"))
+	w.Write([]byte("tpass ok\ndb_password=abc123 token: 'xyz'\n"))
+	w.Write([]byte("no newline s3cr3tpass"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "connect with ****** ok\ndb_password=****** token: '******'\nno newline ******"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestRedactWriter_Concurrent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := newRedactWriter(nopCloser{buf}, []string{"s3cr3tpass"})
+
+	// the stdout and stderr of the command are copied by different goroutines
+	script := `for i in $(seq 1 200); do echo "out $i s3cr3tpass"; echo "err $i s3cr3tpass" >&2; done`
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Stdout = io.MultiWriter(io.Discard, w)
+	cmd.Stderr = io.MultiWriter(io.Discard, w)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "s3cr3tpass") {
+		t.Errorf("the secret is not masked:\n%s", buf.String())
+	}
+	if n := strings.Count(buf.String(), "******"); n != 400 {
+		t.Errorf("expected 400 masked secrets, got %d", n)
+	}
+}
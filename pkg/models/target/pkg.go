@@ -0,0 +1,46 @@
+package target
+
+import (
+	"io"
+
+	"github.com/bougou/sail/pkg/models/product"
+)
+
+// FetchPkgs downloads the missing pkg files of the enabled components into the packages dir.
+// If no components are passed, all enabled components of the zone are considered.
+// The progress of downloading is written to out.
+func (zone *Zone) FetchPkgs(out io.Writer, componentsMaps ...map[string]string) error {
+	filterOptions := []product.FilterOption{}
+	for _, m := range componentsMaps {
+		if len(m) != 0 {
+			filterOptions = append(filterOptions, product.NewFilterOptionByComponentsMap(m))
+		}
+	}
+
+	componentNames := zone.Product.ComponentListWithFilterOptionsAnd(product.FilterOptionEnabled)
+	if len(filterOptions) != 0 {
+		chosen := zone.Product.ComponentListWithFitlerOptionsOr(filterOptions...)
+		componentNames = intersectSliceString(componentNames, chosen)
+	}
+
+	if len(componentNames) == 0 {
+		return nil
+	}
+
+	return zone.Product.DownloadPkgs(zone.sailOption.PackagesDir, out, componentNames...)
+}
+
+func intersectSliceString(a []string, b []string) []string {
+	m := make(map[string]bool)
+	for _, v := range b {
+		m[v] = true
+	}
+
+	out := []string{}
+	for _, v := range a {
+		if m[v] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
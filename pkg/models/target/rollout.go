@@ -0,0 +1,495 @@
+package target
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bougou/gopkg/common"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	GateTypeTCP  = "tcp"
+	GateTypeHTTP = "http"
+
+	WaveStatusPending   = "pending"
+	WaveStatusSucceeded = "succeeded"
+	WaveStatusFailed    = "failed"
+	// WaveStatusAwaitingConfirmation means the wave succeeded, but the next wave is not confirmed yet.
+	WaveStatusAwaitingConfirmation = "awaiting-confirmation"
+
+	defaultGateTimeout  = time.Minute
+	defaultGateInterval = 5 * time.Second
+)
+
+// Rollout is the staged rollout plan of the target, defined in <target>/rollout.yaml.
+// The zones are upgraded wave by wave, eg:
+//
+//	waves:
+//	  - name: canary
+//	    selector: tier=canary
+//	    confirm: true
+//	    gates:
+//	      - type: http
+//	        component: foobar-api
+//	        path: /healthz
+//	  - name: first-quarter
+//	    percent: 25
+//	    pause: 10m
+//	  - name: rest
+type Rollout struct {
+	Waves []*Wave `yaml:"waves"`
+}
+
+// Wave is a group of zones upgraded together.
+// The zones of the wave are the zones not chosen by previous waves which match
+// the zones globs and the label selector. If percent is set, only the given percent
+// of all zones of the target are chosen from them. A wave without zones, selector
+// and percent chooses all the rest zones.
+type Wave struct {
+	Name     string   `yaml:"name"`
+	Zones    []string `yaml:"zones,omitempty"`
+	Selector string   `yaml:"selector,omitempty"`
+	Percent  int      `yaml:"percent,omitempty"`
+
+	// Pause is the duration (eg: 10m) to wait after the wave succeeded, before the next wave.
+	Pause string `yaml:"pause,omitempty"`
+	// Confirm asks for confirmation after the wave succeeded, before the next wave.
+	Confirm bool `yaml:"confirm,omitempty"`
+
+	// Gates are checked for every zone of the wave after the upgrade,
+	// the wave fails if any gate does not pass.
+	Gates []*Gate `yaml:"gates,omitempty"`
+
+	pause    time.Duration
+	selector Selector
+}
+
+// Gate checks the computed endpoints of the services of a component are healthy.
+// The tcp gate connects to the computed addrs, and the http gate requests the computed
+// endpoints with the path and expects a 2xx or 3xx status.
+type Gate struct {
+	Type      string `yaml:"type"`
+	Component string `yaml:"component"`
+	// Service is the service name of the component, all services are checked if empty.
+	Service string `yaml:"service,omitempty"`
+	// Path is the http path, default to the computed path of the service.
+	Path string `yaml:"path,omitempty"`
+	// Timeout is the duration to wait for the gate to pass, default 1m.
+	Timeout string `yaml:"timeout,omitempty"`
+	// Interval is the duration between retries, default 5s.
+	Interval string `yaml:"interval,omitempty"`
+
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// RolloutFile returns the file path of the rollout plan of the target.
+func (t *Target) RolloutFile() string {
+	return path.Join(t.dir, "rollout.yaml")
+}
+
+// LoadRollout loads and validates the rollout plan of the target.
+func (t *Target) LoadRollout() (*Rollout, error) {
+	b, err := os.ReadFile(t.RolloutFile())
+	if err != nil {
+		return nil, fmt.Errorf("read rollout file failed, err: %s", err)
+	}
+
+	r := &Rollout{}
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(r); err != nil {
+		return nil, fmt.Errorf("decode rollout file (%s) failed, err: %s", t.RolloutFile(), err)
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, fmt.Errorf("invalid rollout file (%s), %s", t.RolloutFile(), err)
+	}
+
+	return r, nil
+}
+
+func (r *Rollout) validate() error {
+	if len(r.Waves) == 0 {
+		return errors.New("no waves defined")
+	}
+
+	names := make(map[string]bool)
+	for i, wave := range r.Waves {
+		if wave.Name == "" {
+			return fmt.Errorf("the name of wave #%d is empty", i+1)
+		}
+		if names[wave.Name] {
+			return fmt.Errorf("duplicated wave name (%s)", wave.Name)
+		}
+		names[wave.Name] = true
+
+		if err := wave.validate(); err != nil {
+			return fmt.Errorf("wave (%s): %s", wave.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (wave *Wave) validate() error {
+	for _, glob := range wave.Zones {
+		if err := ValidateZoneGlob(glob); err != nil {
+			return err
+		}
+	}
+
+	selector, err := ParseSelector(wave.Selector)
+	if err != nil {
+		return err
+	}
+	wave.selector = selector
+
+	if wave.Percent < 0 || wave.Percent > 100 {
+		return fmt.Errorf("invalid percent (%d), must be between 0 and 100", wave.Percent)
+	}
+
+	if wave.Pause != "" {
+		d, err := time.ParseDuration(wave.Pause)
+		if err != nil {
+			return fmt.Errorf("invalid pause (%s), err: %s", wave.Pause, err)
+		}
+		wave.pause = d
+	}
+
+	for _, gate := range wave.Gates {
+		if err := gate.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PauseDuration returns the duration to wait after the wave succeeded.
+func (wave *Wave) PauseDuration() time.Duration {
+	return wave.pause
+}
+
+func (gate *Gate) validate() error {
+	switch gate.Type {
+	case GateTypeTCP, GateTypeHTTP:
+	default:
+		return fmt.Errorf("not supported gate type (%s), valid values: tcp, http", gate.Type)
+	}
+
+	if gate.Component == "" {
+		return fmt.Errorf("the component of %s gate is empty", gate.Type)
+	}
+
+	gate.timeout = defaultGateTimeout
+	if gate.Timeout != "" {
+		d, err := time.ParseDuration(gate.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid gate timeout (%s), err: %s", gate.Timeout, err)
+		}
+		gate.timeout = d
+	}
+
+	gate.interval = defaultGateInterval
+	if gate.Interval != "" {
+		d, err := time.ParseDuration(gate.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid gate interval (%s), err: %s", gate.Interval, err)
+		}
+		gate.interval = d
+	}
+
+	return nil
+}
+
+// Plan returns the names of the zones of each wave.
+// The zones of the target which are not chosen by any wave are returned as the rest.
+func (r *Rollout) Plan(t *Target) ([][]string, []string, error) {
+	zoneNames, err := t.AllZones()
+	if err != nil {
+		return nil, nil, fmt.Errorf("determine all zones for target (%s) failed, err: %s", t.Name, err)
+	}
+	sort.Strings(zoneNames)
+
+	labels := make(map[string]Labels)
+	for _, zoneName := range zoneNames {
+		zoneMeta, err := NewZone(t.sailOption, t.Name, zoneName).ParseZoneMeta()
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse zone meta for zone (%s) failed, err: %s", zoneName, err)
+		}
+		labels[zoneName] = zoneMeta.SailLabels
+	}
+
+	chosen := make(map[string]bool)
+	plan := [][]string{}
+	for _, wave := range r.Waves {
+		candidates := []string{}
+		for _, zoneName := range zoneNames {
+			if chosen[zoneName] {
+				continue
+			}
+			if len(wave.Zones) != 0 && !MatchZoneGlobs(wave.Zones, zoneName) {
+				continue
+			}
+			if !wave.selector.Matches(labels[zoneName]) {
+				continue
+			}
+			candidates = append(candidates, zoneName)
+		}
+
+		if wave.Percent > 0 {
+			n := int(math.Ceil(float64(len(zoneNames)*wave.Percent) / 100))
+			if n < len(candidates) {
+				candidates = candidates[:n]
+			}
+		}
+
+		for _, zoneName := range candidates {
+			chosen[zoneName] = true
+		}
+		plan = append(plan, candidates)
+	}
+
+	rest := []string{}
+	for _, zoneName := range zoneNames {
+		if !chosen[zoneName] {
+			rest = append(rest, zoneName)
+		}
+	}
+
+	return plan, rest, nil
+}
+
+// Check waits until the gate passes for the zone or the timeout is reached.
+// The zone must be loaded and computed.
+func (gate *Gate) Check(zone *Zone) error {
+	targets, err := gate.targets(zone)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(gate.timeout)
+	for {
+		failed := []string{}
+		for _, target := range targets {
+			if err := gate.probe(target); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %s", target, err))
+			}
+		}
+		if len(failed) == 0 {
+			return nil
+		}
+
+		if time.Now().Add(gate.interval).After(deadline) {
+			return fmt.Errorf("%s gate for component (%s) not passed in %s:\n%s", gate.Type, gate.Component, gate.timeout, strings.Join(failed, "\n"))
+		}
+		time.Sleep(gate.interval)
+	}
+}
+
+// targets returns the computed addrs (tcp) or urls (http) to probe.
+func (gate *Gate) targets(zone *Zone) ([]string, error) {
+	c, ok := zone.Product.Components[gate.Component]
+	if !ok {
+		return nil, fmt.Errorf("not found component (%s) in product", gate.Component)
+	}
+
+	svcNames := []string{}
+	for svcName := range c.Computed {
+		if gate.Service == "" || gate.Service == svcName {
+			svcNames = append(svcNames, svcName)
+		}
+	}
+	sort.Strings(svcNames)
+	if len(svcNames) == 0 {
+		return nil, fmt.Errorf("not found computed service (%s) for component (%s)", gate.Service, gate.Component)
+	}
+
+	targets := []string{}
+	for _, svcName := range svcNames {
+		computed := c.Computed[svcName]
+		if gate.Type == GateTypeTCP {
+			targets = append(targets, computed.Addrs...)
+			continue
+		}
+
+		p := gate.Path
+		if p == "" {
+			p = computed.Path
+		}
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		for _, endpoint := range computed.Endpoints {
+			targets = append(targets, strings.TrimSuffix(endpoint, "/")+p)
+		}
+	}
+
+	return targets, nil
+}
+
+func (gate *Gate) probe(target string) error {
+	if gate.Type == GateTypeTCP {
+		conn, err := net.DialTimeout("tcp", target, gate.interval)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	client := &http.Client{Timeout: gate.interval}
+	resp, err := client.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected http status: %s", resp.Status)
+	}
+	return nil
+}
+
+// RolloutProgress records the progress of a rollout, so that a stopped rollout can be resumed.
+// It is saved to <target>/.rollout-progress.yaml.
+type RolloutProgress struct {
+	Components []string        `json:"components" yaml:"components"`
+	StartedAt  time.Time       `json:"startedAt" yaml:"startedAt"`
+	UpdatedAt  time.Time       `json:"updatedAt" yaml:"updatedAt"`
+	Waves      []*WaveProgress `json:"waves" yaml:"waves"`
+}
+
+// WaveProgress is the progress of a wave of the rollout.
+type WaveProgress struct {
+	Name    string    `json:"name" yaml:"name"`
+	Zones   []string  `json:"zones" yaml:"zones"`
+	Status  string    `json:"status" yaml:"status"`
+	Error   string    `json:"error,omitempty" yaml:"error,omitempty"`
+	EndedAt time.Time `json:"endedAt,omitempty" yaml:"endedAt,omitempty"`
+}
+
+// NewRolloutProgress returns the progress of a new rollout of the components, all waves are pending.
+func NewRolloutProgress(components []string, waves []*Wave, plan [][]string) *RolloutProgress {
+	p := &RolloutProgress{
+		Components: components,
+		StartedAt:  time.Now(),
+		Waves:      []*WaveProgress{},
+	}
+	for i, wave := range waves {
+		p.Waves = append(p.Waves, &WaveProgress{
+			Name:   wave.Name,
+			Zones:  plan[i],
+			Status: WaveStatusPending,
+		})
+	}
+	return p
+}
+
+// Wave returns the progress of the wave with the name, or nil if not found.
+func (p *RolloutProgress) Wave(name string) *WaveProgress {
+	for _, w := range p.Waves {
+		if w.Name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+// Completed returns whether all waves succeeded.
+func (p *RolloutProgress) Completed() bool {
+	for _, w := range p.Waves {
+		if w.Status != WaveStatusSucceeded {
+			return false
+		}
+	}
+	return true
+}
+
+// SameComponents returns whether the progress is for the same components (and versions).
+func (p *RolloutProgress) SameComponents(components []string) bool {
+	a := append([]string{}, p.Components...)
+	b := append([]string{}, components...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+// CheckPlan checks that the current plan adds no zones to the waves which are already done
+// (succeeded or awaiting confirmation), eg: a zone is created or its labels are changed since the rollout started.
+// Such zones would never be upgraded by the resumed rollout, as the done waves are skipped.
+func (p *RolloutProgress) CheckPlan(waves []*Wave, plan [][]string) error {
+	errs := []string{}
+	for i, wave := range waves {
+		wp := p.Wave(wave.Name)
+		if wp == nil || (wp.Status != WaveStatusSucceeded && wp.Status != WaveStatusAwaitingConfirmation) {
+			continue
+		}
+
+		done := make(map[string]bool)
+		for _, zoneName := range wp.Zones {
+			done[zoneName] = true
+		}
+		added := []string{}
+		for _, zoneName := range plan[i] {
+			if !done[zoneName] {
+				added = append(added, zoneName)
+			}
+		}
+		if len(added) != 0 {
+			errs = append(errs, fmt.Sprintf("wave (%s): %s", wave.Name, strings.Join(added, ", ")))
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("the zones below are added to the waves already done since the rollout started, they would not be upgraded:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// RolloutProgressFile returns the file path of the rollout progress of the target.
+func (t *Target) RolloutProgressFile() string {
+	return path.Join(t.dir, ".rollout-progress.yaml")
+}
+
+// LoadRolloutProgress returns the recorded rollout progress of the target, or nil if not found.
+func (t *Target) LoadRolloutProgress() (*RolloutProgress, error) {
+	b, err := os.ReadFile(t.RolloutProgressFile())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read rollout progress file failed, err: %s", err)
+	}
+
+	p := &RolloutProgress{}
+	if err := yaml.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("unmarshal rollout progress file failed, err: %s", err)
+	}
+	return p, nil
+}
+
+// SaveRolloutProgress writes the rollout progress of the target to disk.
+func (t *Target) SaveRolloutProgress(p *RolloutProgress) error {
+	p.UpdatedAt = time.Now()
+
+	b, err := common.Encode("yaml", p)
+	if err != nil {
+		return fmt.Errorf("encode rollout progress failed, err: %s", err)
+	}
+
+	if err := writeFileAtomic(t.RolloutProgressFile(), b, 0644); err != nil {
+		return fmt.Errorf("write rollout progress file failed, err: %s", err)
+	}
+	return nil
+}
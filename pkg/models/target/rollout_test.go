@@ -0,0 +1,178 @@
+package target
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRollout_Plan(t *testing.T) {
+	sailOption := newTestSailOption(t)
+	zones := map[string]string{
+		"canary-1": "tier: canary",
+		"east-1":   "region: east",
+		"east-2":   "region: east",
+		"east-3":   "region: east",
+		"west-1":   "region: west",
+		"west-2":   "region: west",
+		"west-3":   "region: west",
+		"west-4":   "region: west",
+	}
+	for zoneName, labels := range zones {
+		writeTestFiles(t, path.Join(sailOption.TargetsDir, "t1", zoneName), map[string]string{
+			"vars.yaml": "_sail_product: foobar\n_sail_labels:\n  " + labels + "\n",
+		})
+	}
+	tg := NewTarget(sailOption, "t1")
+
+	writeTestFiles(t, path.Join(sailOption.TargetsDir, "t1"), map[string]string{
+		"rollout.yaml": `waves:
+  - name: canary
+    selector: tier=canary
+  # 25% of 8 zones is 2 zones
+  - name: east-quarter
+    zones: ["east-*"]
+    percent: 25
+  # 10% of 8 zones is rounded up to 1 zone
+  - name: west-one
+    zones: ["west-*", "north-*"]
+    selector: "!tier"
+    percent: 10
+  - name: west-rest
+    zones: ["west-*"]
+  - name: none
+    selector: tier=canary
+`,
+	})
+	r, err := tg.LoadRollout()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, rest, err := r.Plan(tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"canary-1"},
+		{"east-1", "east-2"},
+		{"west-1"},
+		{"west-2", "west-3", "west-4"},
+		{},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("expected plan %v, got %v", expected, plan)
+	}
+	if !reflect.DeepEqual(rest, []string{"east-3"}) {
+		t.Errorf("expected rest zones [east-3], got %v", rest)
+	}
+
+	// a wave without zones, selector and percent chooses all the rest zones
+	r.Waves = append(r.Waves[:1], &Wave{Name: "all"})
+	plan, rest, err = r.Plan(tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan[1]) != 7 || len(rest) != 0 {
+		t.Errorf("expected all the rest zones in the last wave, got %v, rest %v", plan[1], rest)
+	}
+}
+
+func TestRollout_Validate(t *testing.T) {
+	tests := []struct {
+		rollout string
+		err     string
+	}{
+		{"waves: []", "no waves defined"},
+		{"waves:\n  - zones: [a]", "the name of wave #1 is empty"},
+		{"waves:\n  - name: a\n  - name: a", "duplicated wave name (a)"},
+		{"waves:\n  - name: a\n    zones: ['[a']", "invalid zones pattern ([a)"},
+		{"waves:\n  - name: a\n    selector: tier=a b", "invalid selector"},
+		{"waves:\n  - name: a\n    percent: 101", "invalid percent (101)"},
+		{"waves:\n  - name: a\n    pause: 10", "invalid pause (10)"},
+		{"waves:\n  - name: a\n    gates:\n      - type: grpc\n        component: foobar-api", "not supported gate type (grpc)"},
+		{"waves:\n  - name: a\n    gates:\n      - type: tcp", "the component of tcp gate is empty"},
+		{"waves:\n  - name: a\n    gates:\n      - type: http\n        component: foobar-api\n        timeout: 1", "invalid gate timeout (1)"},
+		{"waves:\n  - name: a\n    confirmed: true", "field confirmed not found"},
+	}
+
+	sailOption := newTestSailOption(t)
+	tg := NewTarget(sailOption, "t1")
+	for _, tt := range tests {
+		writeTestFiles(t, path.Join(sailOption.TargetsDir, "t1"), map[string]string{"rollout.yaml": tt.rollout})
+		_, err := tg.LoadRollout()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("rollout (%s): expected error (%s), got %v", tt.rollout, tt.err, err)
+		}
+	}
+
+	writeTestFiles(t, path.Join(sailOption.TargetsDir, "t1"), map[string]string{
+		"rollout.yaml": "waves:\n  - name: a\n    pause: 10m\n    gates:\n      - type: tcp\n        component: foobar-api\n",
+	})
+	r, err := tg.LoadRollout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Waves[0].PauseDuration() != 10*time.Minute {
+		t.Errorf("expected pause 10m, got %s", r.Waves[0].PauseDuration())
+	}
+	if gate := r.Waves[0].Gates[0]; gate.timeout != defaultGateTimeout || gate.interval != defaultGateInterval {
+		t.Errorf("expected the default gate timeout and interval, got %s and %s", gate.timeout, gate.interval)
+	}
+}
+
+func TestRolloutProgress(t *testing.T) {
+	sailOption := newTestSailOption(t)
+	writeTestFiles(t, path.Join(sailOption.TargetsDir, "t1", "z1"), map[string]string{"vars.yaml": "_sail_product: foobar\n"})
+	tg := NewTarget(sailOption, "t1")
+
+	p, err := tg.LoadRolloutProgress()
+	if err != nil || p != nil {
+		t.Fatalf("expected no progress, got %v, err: %v", p, err)
+	}
+
+	waves := []*Wave{{Name: "canary", Confirm: true}, {Name: "rest"}}
+	p = NewRolloutProgress([]string{"foobar-api/v2", "foobar-web/v3"}, waves, [][]string{{"z1"}, {}})
+	p.Wave("canary").Status = WaveStatusAwaitingConfirmation
+	if err := tg.SaveRolloutProgress(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// the resumed rollout
+	p, err = tg.LoadRolloutProgress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.SameComponents([]string{"foobar-web/v3", "foobar-api/v2"}) {
+		t.Error("expected same components regardless of the order")
+	}
+	if p.SameComponents([]string{"foobar-api/v3", "foobar-web/v3"}) {
+		t.Error("expected different components for different versions")
+	}
+	if w := p.Wave("canary"); w == nil || w.Status != WaveStatusAwaitingConfirmation || !reflect.DeepEqual(w.Zones, []string{"z1"}) {
+		t.Errorf("unexpected progress of wave canary: %+v", w)
+	}
+	if p.Wave("none") != nil {
+		t.Error("expected nil progress for unknown wave")
+	}
+	if p.Completed() {
+		t.Error("expected not completed when a wave is awaiting confirmation")
+	}
+
+	// the zones added to the done waves are reported, the zones of the pending waves are planned again
+	if err := p.CheckPlan(waves, [][]string{{"z1"}, {"z3"}}); err != nil {
+		t.Errorf("expected no error for the unchanged done waves, got %s", err)
+	}
+	if err := p.CheckPlan(waves, [][]string{{"z1", "z2"}, {}}); err == nil || !strings.Contains(err.Error(), "wave (canary): z2") {
+		t.Errorf("expected error for the zone added to the done wave, got %v", err)
+	}
+
+	for _, w := range p.Waves {
+		w.Status = WaveStatusSucceeded
+	}
+	if !p.Completed() {
+		t.Error("expected completed when all waves succeeded")
+	}
+}
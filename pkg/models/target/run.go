@@ -0,0 +1,187 @@
+package target
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bougou/gopkg/common"
+	"gopkg.in/yaml.v3"
+)
+
+// RunRecord records a run of ansible-playbook and/or helm for the zone.
+// The records are saved under <zone>/.runs/<id>.yaml, and the output of the run is saved to <logDir>/<id>.log.
+type RunRecord struct {
+	ID        string    `json:"id" yaml:"id"`
+	Operation string    `json:"operation" yaml:"operation"`
+	Command   string    `json:"command" yaml:"command"`
+	StartedAt time.Time `json:"startedAt" yaml:"startedAt"`
+	EndedAt   time.Time `json:"endedAt" yaml:"endedAt"`
+
+	// the components chosen by the command, empty means all components
+	Components []string `json:"components" yaml:"components"`
+	Tags       []string `json:"tags" yaml:"tags"`
+
+	Results []*ComponentResult `json:"results" yaml:"results"`
+
+	ExitCode int    `json:"exitCode" yaml:"exitCode"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+	LogFile  string `json:"logFile" yaml:"logFile"`
+}
+
+// ComponentResult is the result of the component in a run.
+// The server components share the result of the single ansible-playbook process.
+// Version is the version attempted by the run, EffectiveVersion is the version in the zone vars file after the run,
+// they differ if the run failed or the version is not persisted.
+type ComponentResult struct {
+	Component        string    `json:"component" yaml:"component"`
+	Version          string    `json:"version" yaml:"version"`
+	EffectiveVersion string    `json:"effectiveVersion,omitempty" yaml:"effectiveVersion,omitempty"`
+	Form             string    `json:"form" yaml:"form"`
+	Success          bool      `json:"success" yaml:"success"`
+	ExitCode         int       `json:"exitCode" yaml:"exitCode"`
+	EndedAt          time.Time `json:"endedAt" yaml:"endedAt"`
+}
+
+// LastComponentResult is the last result of the component among all runs.
+type LastComponentResult struct {
+	*ComponentResult
+	RunID     string `json:"runId" yaml:"runId"`
+	Operation string `json:"operation" yaml:"operation"`
+
+	// LastSuccess is the last successful result of the component, which is the deployed version,
+	// nil if the component never succeeded.
+	LastSuccess *ComponentResult `json:"lastSuccess,omitempty" yaml:"lastSuccess,omitempty"`
+}
+
+func (zone *Zone) runRecordFile(id string) string {
+	return path.Join(zone.RunsDir, id+".yaml")
+}
+
+// NewRunRecord creates a run record and saves it.
+func (zone *Zone) NewRunRecord(operation string) (*RunRecord, error) {
+	if err := os.MkdirAll(zone.RunsDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("make runs dir failed, err: %s", err)
+	}
+
+	now := time.Now()
+	id := now.Format(snapshotIDLayout)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(zone.runRecordFile(id)); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", now.Format(snapshotIDLayout), i)
+	}
+
+	r := &RunRecord{
+		ID:         id,
+		Operation:  operation,
+		Command:    strings.Join(os.Args, " "),
+		StartedAt:  now,
+		Components: []string{},
+		Tags:       []string{},
+		Results:    []*ComponentResult{},
+	}
+
+	return r, zone.SaveRunRecord(r)
+}
+
+// SaveRunRecord writes the run record to disk.
+func (zone *Zone) SaveRunRecord(r *RunRecord) error {
+	b, err := common.Encode("yaml", r)
+	if err != nil {
+		return fmt.Errorf("encode run record failed, err: %s", err)
+	}
+
+	if err := os.WriteFile(zone.runRecordFile(r.ID), b, 0644); err != nil {
+		return fmt.Errorf("write run record file failed, err: %s", err)
+	}
+
+	return nil
+}
+
+// RunRecords returns all run records of the zone, ordered from oldest to newest.
+func (zone *Zone) RunRecords() ([]*RunRecord, error) {
+	entries, err := os.ReadDir(zone.RunsDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*RunRecord{}, nil
+		}
+		return nil, fmt.Errorf("read runs dir failed, err: %s", err)
+	}
+
+	records := []*RunRecord{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+
+		f := path.Join(zone.RunsDir, entry.Name())
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read file (%s) failed, err: %s", f, err)
+		}
+
+		r := &RunRecord{}
+		if err := yaml.Unmarshal(b, r); err != nil {
+			return nil, fmt.Errorf("unmarshal run record (%s) failed, err: %s", f, err)
+		}
+		records = append(records, r)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].StartedAt.Equal(records[j].StartedAt) {
+			return records[i].ID < records[j].ID
+		}
+		return records[i].StartedAt.Before(records[j].StartedAt)
+	})
+
+	return records, nil
+}
+
+// LastComponentResults returns the last result and the last successful result of each component among all runs of the zone.
+func (zone *Zone) LastComponentResults() (map[string]*LastComponentResult, error) {
+	records, err := zone.RunRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]*LastComponentResult)
+	for _, r := range records {
+		for _, result := range r.Results {
+			last := &LastComponentResult{
+				ComponentResult: result,
+				RunID:           r.ID,
+				Operation:       r.Operation,
+			}
+			if prev, ok := out[result.Component]; ok {
+				last.LastSuccess = prev.LastSuccess
+			}
+			if result.Success {
+				last.LastSuccess = result
+			}
+			out[result.Component] = last
+		}
+	}
+
+	return out, nil
+}
+
+// exitCode returns the exit code of the command from the error returned by running it.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return 1
+}
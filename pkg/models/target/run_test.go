@@ -0,0 +1,45 @@
+package target
+
+import (
+	"testing"
+)
+
+func TestZone_LastComponentResults(t *testing.T) {
+	zone := newTestZone(t, newTestSailOption(t), "t1", "z1", "", "")
+
+	runs := []struct {
+		version string
+		success bool
+	}{
+		{"v1.0.0", true},
+		{"v1.1.0", true},
+		{"v1.2.0", false},
+		{"v1.3.0", false},
+	}
+	for _, run := range runs {
+		r, err := zone.NewRunRecord("upgrade")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Results = append(r.Results, &ComponentResult{Component: "foobar-api", Version: run.version, Success: run.success})
+		if err := zone.SaveRunRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := zone.LastComponentResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := results["foobar-api"]
+	if last == nil || last.Version != "v1.3.0" || last.Success {
+		t.Fatalf("expected the last failed result of v1.3.0, got %+v", last)
+	}
+	if last.LastSuccess == nil || last.LastSuccess.Version != "v1.1.0" {
+		t.Errorf("expected the last successful result of v1.1.0, got %+v", last.LastSuccess)
+	}
+
+	if _, ok := results["foobar-db"]; ok {
+		t.Error("expected no result for the component never run")
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	newexec "github.com/bougou/gopkg/exec"
 	"github.com/bougou/sail/pkg/ansible"
@@ -33,8 +36,35 @@ type RunningZone struct {
 	ansiblePlaybookArgs []string
 
 	helmSetArgs []string
+
+	// only print the commands, do not execute them
+	dryRun bool
+
+	// the operation name (eg: apply, upgrade) recorded in the run record
+	operation string
+	record    *RunRecord
+	logFile   io.WriteCloser
+
+	// canceled once sail is interrupted or terminated, the running commands are killed
+	ctx context.Context
+
+	// the standard streams of the commands, nil stdin means no input
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// the temporary file of the decrypted secrets of the zone, empty if the zone has no secrets
+	secretsFile string
+
+	// the temporary file of the components whose versions are staged, empty if no staged versions
+	stagedFile string
+	// do not commit the staged versions to the zone vars file even if the run succeeded
+	noPersist bool
 }
 
+// ansibleCfgMu guards the generation of the ansible.cfg file shared by all zones.
+var ansibleCfgMu sync.Mutex
+
 func (rz *RunningZone) WithServerComponents(serverComponents map[string]string) {
 	for componentName := range serverComponents {
 		rz.serverComponents = append(rz.serverComponents, componentName)
@@ -51,20 +81,45 @@ func (rz *RunningZone) WithStartAtPlay(startAtPlay string) {
 	rz.startAtPlay = startAtPlay
 }
 
+func (rz *RunningZone) WithDryRun(dryRun bool) {
+	rz.dryRun = dryRun
+}
+
+// WithNoPersist makes the staged component versions only used by the run, they are never written to the zone vars file.
+func (rz *RunningZone) WithNoPersist(noPersist bool) {
+	rz.noPersist = noPersist
+}
+
+func (rz *RunningZone) WithOperation(operation string) {
+	rz.operation = operation
+}
+
+// WithIO sets the standard streams of the commands run by the running zone.
+// Pass nil stdin when the zone is not run in the foreground (eg: multiple zones are run in parallel).
+func (rz *RunningZone) WithIO(stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	rz.stdin = stdin
+	rz.stdout = stdout
+	rz.stderr = stderr
+}
+
 func (rz *RunningZone) WithAnsiblePlaybookTags(ansiblePlaybookTags []string) {
-	rz.ansiblePlaybookTags = ansiblePlaybookTags
+	rz.ansiblePlaybookTags = append([]string{}, ansiblePlaybookTags...)
 }
 
 func NewRunningZone(zone *Zone, playbookName string) *RunningZone {
 	rz := &RunningZone{
 		zone:     zone,
 		playbook: playbookName,
+
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
 	}
 
 	rz.ansiblePlaybookArgs = []string{
 		zone.PlaybookFile(playbookName),
 		"-i",
-		zone.HostsFile,
+		zone.AnsibleInventoryFile(),
 		"-e",
 		"@" + zone.VarsFile,
 		"-e",
@@ -84,6 +139,7 @@ func NewRunningZone(zone *Zone, playbookName string) *RunningZone {
 		"-e",
 		"sail_zone_name=" + zone.ZoneName,
 	}
+	rz.ansiblePlaybookArgs = append(rz.ansiblePlaybookArgs, zone.VaultArgs()...)
 
 	rz.helmSetArgs = []string{
 		"--set",
@@ -111,7 +167,40 @@ func NewRunningZone(zone *Zone, playbookName string) *RunningZone {
 	return rz
 }
 
-func (rz *RunningZone) Run(args []string) error {
+func (rz *RunningZone) Run(args []string) (err error) {
+	ctx, release := watchSignals()
+	defer release()
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	rz.ctx = ctx
+
+	if rz.zone.HasSecrets() {
+		cleanup, err := rz.prepareSecrets()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+	}
+
+	if len(rz.zone.StagedComponents()) != 0 {
+		cleanup, err := rz.prepareStaged()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+	}
+
+	if !rz.dryRun {
+		if err := rz.startRecord(); err != nil {
+			return err
+		}
+		defer func() {
+			rz.commitVersions()
+			rz.finishRecord(err)
+		}()
+	}
+
 	// If not specify any components, it means all components.
 	// So run ansible-playbook, then helm.
 	if len(rz.serverComponents) == 0 && len(rz.podComponents) == 0 {
@@ -140,68 +229,118 @@ func (rz *RunningZone) Run(args []string) error {
 }
 
 func (rz *RunningZone) RunAnsiblePlaybook(args []string) error {
+	// copy the args, so the running zone is not changed by running
+	ansiblePlaybookArgs := append([]string{}, rz.ansiblePlaybookArgs...)
+
 	// ansible-playbook tags set by sail commands.
 	if len(rz.ansiblePlaybookTags) != 0 {
-		rz.ansiblePlaybookArgs = append(rz.ansiblePlaybookArgs, "--tags", strings.Join(rz.ansiblePlaybookTags, ","))
+		ansiblePlaybookArgs = append(ansiblePlaybookArgs, "--tags", strings.Join(rz.ansiblePlaybookTags, ","))
 	}
 
 	// parse ansible playbook file to get tags for startAtPlay
-	playbookFile := rz.zone.PlaybookFile(rz.playbook)
-	playbook, err := ansible.NewPlaybookFromFile(playbookFile)
+	playbook, err := rz.loadPlaybook()
 	if err != nil {
-		return fmt.Errorf("NewPlaybookFromFile failed, err: %s", err)
+		return fmt.Errorf("load playbook failed, err: %s", err)
 	}
 	if rz.startAtPlay != "" {
 		playbookTags := playbook.PlaysTagsStartAt(rz.startAtPlay)
 		if len(playbookTags) != 0 {
-			rz.ansiblePlaybookArgs = append(rz.ansiblePlaybookArgs, "--tags", strings.Join(playbookTags, ","))
+			ansiblePlaybookArgs = append(ansiblePlaybookArgs, "--tags", strings.Join(playbookTags, ","))
 		}
 	}
 
-	if len(args) > 0 {
-		rz.ansiblePlaybookArgs = append(rz.ansiblePlaybookArgs, args...)
+	// the staged components take precedence over the components in the vars of the zone
+	if rz.stagedFile != "" {
+		ansiblePlaybookArgs = append(ansiblePlaybookArgs, "-e", "@"+rz.stagedFile)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// the secrets take precedence over the vars of the zone
+	if rz.secretsFile != "" {
+		ansiblePlaybookArgs = append(ansiblePlaybookArgs, "-e", "@"+rz.secretsFile)
+	}
 
-	if _, err := os.Stat(rz.zone.ansibleCfgFile); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			fmt.Println("not found ansible.cfg, generate one")
-			if err := os.WriteFile(rz.zone.ansibleCfgFile, []byte(defaultAnsibleCfg), 0644); err != nil {
-				fmt.Println("write ansible.cfg file failed", err)
-			}
-		}
+	if len(args) > 0 {
+		ansiblePlaybookArgs = append(ansiblePlaybookArgs, args...)
 	}
 
-	cmd := exec.CommandContext(ctx, "ansible-playbook", rz.ansiblePlaybookArgs...)
+	cmd := exec.CommandContext(rz.context(), "ansible-playbook", ansiblePlaybookArgs...)
 	env := []string{
 		"ANSIBLE_FORCE_COLOR=true", // this env var will make ansible-playbook always output color
 		"ANSIBLE_CONFIG=" + rz.zone.ansibleCfgFile,
 	}
 
-	logFileName := "/tmp/sail.log"
-	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0666)
-	if err != nil {
-		return fmt.Errorf("can not create log file: %s, exit", logFileName)
+	if rz.dryRun {
+		fmt.Fprintln(rz.stdout, "⛵ [dry-run] "+newexec.NewCmdEnvWrapper(cmd, env...).String())
+		return nil
+	}
+
+	ansibleCfgMu.Lock()
+	if _, err := os.Stat(rz.zone.ansibleCfgFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(rz.stdout, "not found ansible.cfg, generate one")
+			if err := os.WriteFile(rz.zone.ansibleCfgFile, []byte(defaultAnsibleCfg), 0644); err != nil {
+				fmt.Fprintln(rz.stdout, "write ansible.cfg file failed", err)
+			}
+		}
 	}
+	ansibleCfgMu.Unlock()
 
 	// thus, the cmd's output goes to terminal AND logfile
-	cmd.Stdout = io.MultiWriter(os.Stdout, logFile)
-	cmd.Stderr = io.MultiWriter(os.Stderr, logFile)
+	logFile := rz.logWriter()
+	stdout, stderr := rz.zone.MaskSecrets(rz.stdout), rz.zone.MaskSecrets(rz.stderr)
+	defer stdout.Close()
+	defer stderr.Close()
+	cmd.Stdout = io.MultiWriter(stdout, logFile)
+	cmd.Stderr = io.MultiWriter(stderr, logFile)
 	// ansible-playbook checks if stdin is a tty device `os.isatty(0)`, then set column width accordingly
 	// ref: https://github.com/ansible/ansible/blob/2cbfd1e350cbe1ca195d33306b5a9628667ddda8/lib/ansible/utils/display.py#L534
 	// here, we specifically set to os.Stdin to simulate tty
-	cmd.Stdin = os.Stdin
+	if rz.stdin != nil {
+		cmd.Stdin = rz.stdin
+	}
 
 	cmdWrapper := newexec.NewCmdEnvWrapper(cmd, env...)
-	fmt.Println("⛵ " + cmdWrapper.String())
+	fmt.Fprintln(rz.stdout, "⛵ "+cmdWrapper.String())
 	// cmdWrapper.SetDebug(true)
-	return cmdWrapper.Run()
+	err = rz.interrupted(cmdWrapper.Run())
+	rz.addResults(rz.ansibleComponents(), err)
+	return err
+
+}
+
+// loadPlaybook returns the playbook which will be executed.
+// When dry-run, the default sail playbook is generated in memory, because it is not written to disk.
+func (rz *RunningZone) loadPlaybook() (*ansible.Playbook, error) {
+	playbookFile := rz.zone.PlaybookFile(rz.playbook)
+	if rz.dryRun && playbookFile == rz.zone.Product.SailPlaybookFile() {
+		playbook, err := rz.zone.Product.GenSail()
+		if err != nil {
+			return nil, err
+		}
+		return &playbook, nil
+	}
 
+	return ansible.NewPlaybookFromFile(playbookFile)
 }
 
 func (rz *RunningZone) RunHelm(args []string) error {
+	varsFile, computedFile := rz.zone.VarsFile, rz.zone.ComputedFile
+	if !rz.dryRun {
+		f, cleanup, err := rz.zone.HelmValuesFile(rz.zone.VarsFile)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		varsFile = f
+
+		f, cleanup, err = rz.zone.HelmValuesFile(rz.zone.ComputedFile)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		computedFile = f
+	}
+
 	switch rz.zone.SailHelmMode {
 	case SailHelmModeComponent:
 		for _, componentName := range rz.zone.Product.ComponentListWithFilterOptionsAnd(product.FilterOptionEnabled, product.FilterOptionFormPod) {
@@ -210,8 +349,11 @@ func (rz *RunningZone) RunHelm(args []string) error {
 			k8s := rz.zone.GetK8SForComponent(componentName)
 
 			valuesFiles := []string{}
-			valuesFiles = append(valuesFiles, rz.zone.VarsFile)     // zone VarsFile always exists.
-			valuesFiles = append(valuesFiles, rz.zone.ComputedFile) // zone ComputedFile always exists.
+			valuesFiles = append(valuesFiles, varsFile) // zone VarsFile always exists.
+			if rz.stagedFile != "" {
+				valuesFiles = append(valuesFiles, rz.stagedFile)
+			}
+			valuesFiles = append(valuesFiles, computedFile) // zone ComputedFile always exists.
 
 			zoneGlobalValuesFile := path.Join(rz.zone.HelmDir, "values.yaml") // global helm values.yaml is OPTIONAL.
 			_, err := os.Stat(zoneGlobalValuesFile)
@@ -225,7 +367,9 @@ func (rz *RunningZone) RunHelm(args []string) error {
 			zoneComponentValuesFile := path.Join(rz.zone.HelmDirOfComponent(componentName), "values.yaml")
 			valuesFiles = append(valuesFiles, zoneComponentValuesFile)
 
-			if err := rz.helmCmd(helmRelease, helmChartDir, k8s, valuesFiles, args...); err != nil {
+			err = rz.helmCmd(helmRelease, helmChartDir, k8s, valuesFiles, args...)
+			rz.addResults([]string{componentName}, err)
+			if err != nil {
 				return fmt.Errorf("run helm for component (%s) failed, err: %s", componentName, err)
 			}
 		}
@@ -236,8 +380,11 @@ func (rz *RunningZone) RunHelm(args []string) error {
 		k8s := rz.zone.GetK8SForProduct()
 
 		valuesFiles := []string{}
-		valuesFiles = append(valuesFiles, rz.zone.VarsFile)     // zone VarsFile always exists.
-		valuesFiles = append(valuesFiles, rz.zone.ComputedFile) // zone ComputedFile always exists.
+		valuesFiles = append(valuesFiles, varsFile) // zone VarsFile always exists.
+		if rz.stagedFile != "" {
+			valuesFiles = append(valuesFiles, rz.stagedFile)
+		}
+		valuesFiles = append(valuesFiles, computedFile) // zone ComputedFile always exists.
 
 		zoneGlobalValuesFile := path.Join(rz.zone.HelmDir, "values.yaml") // global values.yaml is OPTIONAL.
 		_, err := os.Stat(zoneGlobalValuesFile)
@@ -248,7 +395,9 @@ func (rz *RunningZone) RunHelm(args []string) error {
 			return fmt.Errorf("access global values.yaml failed, err: %s", err)
 		}
 
-		return rz.helmCmd(helmRelease, helmChartDir, k8s, valuesFiles, args...)
+		err = rz.helmCmd(helmRelease, helmChartDir, k8s, valuesFiles, args...)
+		rz.addResults(rz.zone.Product.ComponentListWithFilterOptionsAnd(product.FilterOptionEnabled, product.FilterOptionFormPod), err)
+		return err
 
 	case "":
 		return nil
@@ -283,31 +432,229 @@ func (rz *RunningZone) helmCmd(release string, chartDir string, k8s *cmdb.K8S, v
 	for _, valuesFile := range valuesFiles {
 		helmArgs = append(helmArgs, "--values", valuesFile)
 	}
+	// the secrets take precedence over the values files
+	if rz.secretsFile != "" {
+		helmArgs = append(helmArgs, "--values", rz.secretsFile)
+	}
 
 	helmArgs = append(helmArgs, rz.helmSetArgs...)
 	helmArgs = append(helmArgs, args...)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	cmd := exec.CommandContext(ctx, "helm", helmArgs...)
+	cmd := exec.CommandContext(rz.context(), "helm", helmArgs...)
 
-	logFileName := "/tmp/sail.log"
-	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0666)
-	if err != nil {
-		return fmt.Errorf("can not create log file: %s, exit", logFileName)
+	if rz.dryRun {
+		fmt.Fprintln(rz.stdout, "⛵ [dry-run] "+newexec.NewCmdEnvWrapper(cmd).String())
+		return nil
 	}
 
 	// thus, the cmd's output goes to terminal AND logfile
-	cmd.Stdout = io.MultiWriter(os.Stdout, logFile)
-	cmd.Stderr = io.MultiWriter(os.Stderr, logFile)
+	logFile := rz.logWriter()
+	stdout, stderr := rz.zone.MaskSecrets(rz.stdout), rz.zone.MaskSecrets(rz.stderr)
+	defer stdout.Close()
+	defer stderr.Close()
+	cmd.Stdout = io.MultiWriter(stdout, logFile)
+	cmd.Stderr = io.MultiWriter(stderr, logFile)
 	// ansible-playbook checks if stdin is a tty device `os.isatty(0)`, then set column width accordingly
 	// ref: https://github.com/ansible/ansible/blob/2cbfd1e350cbe1ca195d33306b5a9628667ddda8/lib/ansible/utils/display.py#L534
 	// here, we specifically set to os.Stdin to simulate tty
-	cmd.Stdin = os.Stdin
+	if rz.stdin != nil {
+		cmd.Stdin = rz.stdin
+	}
 
 	cmdWrapper := newexec.NewCmdEnvWrapper(cmd)
-	fmt.Println("⛵ " + cmdWrapper.String())
+	fmt.Fprintln(rz.stdout, "⛵ "+cmdWrapper.String())
 	// cmdWrapper.SetDebug(true)
-	return cmdWrapper.Run()
+	return rz.interrupted(cmdWrapper.Run())
+
+}
+
+// context returns the context of the running commands.
+func (rz *RunningZone) context() context.Context {
+	if rz.ctx == nil {
+		return context.Background()
+	}
+	return rz.ctx
+}
+
+// interrupted returns the error of the command killed because sail is interrupted.
+func (rz *RunningZone) interrupted(err error) error {
+	if err != nil && rz.context().Err() != nil {
+		return fmt.Errorf("%s, err: %s", errInterrupted, err)
+	}
+	return err
+}
+
+// prepareSecrets decrypts the secrets of the zone, and writes them into a temporary file.
+// The returned function removes the temporary file. When dry-run, the secrets are not decrypted.
+func (rz *RunningZone) prepareSecrets() (func(), error) {
+	if rz.dryRun {
+		rz.secretsFile = "<decrypted " + rz.zone.SecretsFile + ">"
+		return func() {}, nil
+	}
+
+	key, err := SecretKey(rz.zone.sailOption.SecretKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := rz.zone.LoadSecrets(key)
+	if err != nil {
+		return nil, fmt.Errorf("load secrets failed, err: %s", err)
+	}
+
+	secretsFile, cleanup, err := WriteSecretsTempFile(secrets)
+	if err != nil {
+		return nil, err
+	}
+	rz.secretsFile = secretsFile
+
+	return func() {
+		cleanup()
+		rz.secretsFile = ""
+	}, nil
+}
+
+// prepareStaged writes the components whose versions are staged into a temporary file.
+// The returned function removes the temporary file. When dry-run, the file is not written.
+func (rz *RunningZone) prepareStaged() (func(), error) {
+	for _, componentName := range rz.zone.StagedComponents() {
+		fmt.Fprintf(rz.stdout, "staged version of component (%s): %s -> %s\n",
+			componentName, rz.zone.EffectiveVersion(componentName), rz.zone.Product.Components[componentName].Version)
+	}
+
+	if rz.dryRun {
+		rz.stagedFile = "<staged components>"
+		return func() {}, nil
+	}
+
+	stagedFile, cleanup, err := rz.zone.writeStagedFile()
+	if err != nil {
+		return nil, err
+	}
+	rz.stagedFile = stagedFile
+
+	return func() {
+		cleanup()
+		rz.stagedFile = ""
+	}, nil
+}
+
+// commitVersions commits the staged versions of the successfully deployed components to the zone vars file,
+// and records the effective versions of the components into the run record.
+func (rz *RunningZone) commitVersions() {
+	if rz.record == nil {
+		return
+	}
+
+	staged := len(rz.zone.StagedComponents())
+	if staged != 0 && !rz.noPersist {
+		for _, result := range rz.record.Results {
+			if result.Success {
+				rz.zone.CommitComponentVersion(result.Component)
+			}
+		}
+
+		// the vars file is snapshotted before written
+		if len(rz.zone.StagedComponents()) != staged {
+			if err := rz.zone.RenderVars(); err != nil {
+				fmt.Fprintf(rz.stderr, "warn: commit the versions of components failed, err: %s\n", err)
+			}
+		}
+	}
+
+	for _, result := range rz.record.Results {
+		result.EffectiveVersion = rz.zone.EffectiveVersion(result.Component)
+	}
+}
+
+// startRecord creates the run record and opens the log file of the run.
+func (rz *RunningZone) startRecord() error {
+	r, err := rz.zone.NewRunRecord(rz.operation)
+	if err != nil {
+		return fmt.Errorf("create run record failed, err: %s", err)
+	}
+
+	r.Components = append(r.Components, rz.serverComponents...)
+	r.Components = append(r.Components, rz.podComponents...)
+	sort.Strings(r.Components)
+	r.Tags = append(r.Tags, rz.ansiblePlaybookTags...)
+
+	logFile, logFileName, err := rz.zone.OpenRunLog(r.ID)
+	if err != nil {
+		return err
+	}
+	if err := rz.zone.PruneLogs(); err != nil {
+		fmt.Fprintf(rz.stderr, "warn: prune old log files failed, err: %s\n", err)
+	}
+	r.LogFile = logFileName
+
+	rz.record = r
+	rz.logFile = logFile
+
+	return rz.zone.SaveRunRecord(r)
+}
+
+// finishRecord saves the final state of the run record and closes the log file of the run.
+func (rz *RunningZone) finishRecord(err error) {
+	if rz.record == nil {
+		return
+	}
+
+	rz.record.EndedAt = time.Now()
+	rz.record.ExitCode = exitCode(err)
+	if err != nil {
+		rz.record.Error = err.Error()
+	}
+
+	if err := rz.zone.SaveRunRecord(rz.record); err != nil {
+		fmt.Fprintf(rz.stderr, "warn: save run record failed, err: %s\n", err)
+	}
+
+	if rz.logFile != nil {
+		if err := rz.logFile.Close(); err != nil {
+			fmt.Fprintf(rz.stderr, "warn: close log file failed, err: %s\n", err)
+		}
+		rz.logFile = nil
+	}
+}
+
+// logWriter returns the writer of the log file of the run.
+func (rz *RunningZone) logWriter() io.Writer {
+	if rz.logFile == nil {
+		return io.Discard
+	}
+	return rz.logFile
+}
+
+// addResults adds the result of the components to the run record.
+func (rz *RunningZone) addResults(componentNames []string, err error) {
+	if rz.record == nil {
+		return
+	}
+
+	for _, componentName := range componentNames {
+		result := &ComponentResult{
+			Component: componentName,
+			Success:   err == nil,
+			ExitCode:  exitCode(err),
+			EndedAt:   time.Now(),
+		}
+		if c, ok := rz.zone.Product.Components[componentName]; ok {
+			result.Version = c.Version
+			result.Form = c.Form
+		}
+		rz.record.Results = append(rz.record.Results, result)
+	}
+}
+
+// ansibleComponents returns the server components which are deployed by ansible-playbook.
+// If not specify any components, it means all enabled server components.
+func (rz *RunningZone) ansibleComponents() []string {
+	if len(rz.serverComponents) != 0 {
+		out := append([]string{}, rz.serverComponents...)
+		sort.Strings(out)
+		return out
+	}
 
+	return rz.zone.Product.ComponentListWithFilterOptionsAnd(product.FilterOptionEnabled, product.FilterOptionFormServer)
 }
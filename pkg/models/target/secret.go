@@ -0,0 +1,336 @@
+package target
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bougou/gopkg/common"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SecretKeyEnv is the env var holding the key to encrypt and decrypt the secrets of zones.
+	// It takes precedence over the key file specified by --secret-key-file.
+	SecretKeyEnv = "SAIL_SECRET_KEY"
+	// NewSecretKeyEnv is the env var holding the new key when rotating the secrets.
+	NewSecretKeyEnv = "SAIL_NEW_SECRET_KEY"
+
+	// secretKeySize is the size of the AES-256 key.
+	secretKeySize = 32
+
+	secretPrefix = "ENC[AES256_GCM,"
+	secretSuffix = "]"
+
+	secretsFileHeader = "# the secrets of the zone, encrypted by sail, DO NOT edit, use `sail secret` to manage them\n"
+)
+
+// secretNameRegex matches the valid secret names, the secrets are passed as top-level variables.
+var secretNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SecretKey returns the key to encrypt and decrypt the secrets of zones.
+// The key is read from the SAIL_SECRET_KEY env var, or the file specified by --secret-key-file.
+func SecretKey(secretKeyFile string) ([]byte, error) {
+	return secretKeyFrom(SecretKeyEnv, secretKeyFile, "--secret-key-file")
+}
+
+// NewSecretKey returns the new key used to rotate the secrets of zones.
+// The key is read from the SAIL_NEW_SECRET_KEY env var, or the file specified by --new-key-file.
+func NewSecretKey(newKeyFile string) ([]byte, error) {
+	return secretKeyFrom(NewSecretKeyEnv, newKeyFile, "--new-key-file")
+}
+
+// secretKeyFrom reads the key from the env var or the key file.
+// The key must be 32 random bytes (the AES-256 key) encoded in base64 or hex, eg: generated by `openssl rand -base64 32`,
+// passphrases are not accepted, they are too weak to be used as the key directly.
+func secretKeyFrom(env string, keyFile string, keyFileOption string) ([]byte, error) {
+	material := os.Getenv(env)
+	if material == "" && keyFile != "" {
+		b, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read secret key file failed, err: %s", err)
+		}
+		material = strings.TrimSpace(string(b))
+	}
+
+	if material == "" {
+		return nil, fmt.Errorf("not found secret key, set %s env var or specify %s option", env, keyFileOption)
+	}
+
+	key, err := parseSecretKey(material)
+	if err != nil {
+		if os.Getenv(env) != "" {
+			return nil, fmt.Errorf("invalid key in %s env var, err: %s", env, err)
+		}
+		return nil, fmt.Errorf("invalid key in file (%s), err: %s", keyFile, err)
+	}
+	return key, nil
+}
+
+// parseSecretKey decodes the base64 or hex encoded key.
+func parseSecretKey(material string) ([]byte, error) {
+	for _, decode := range []func(string) ([]byte, error){
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		hex.DecodeString,
+	} {
+		if key, err := decode(material); err == nil && len(key) == secretKeySize {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the key must be %d random bytes encoded in base64 or hex, generate one by `openssl rand -base64 %d`", secretKeySize, secretKeySize)
+}
+
+// ValidateSecretName returns error if the name can not be used as a secret name.
+func ValidateSecretName(name string) error {
+	if !secretNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid secret name (%s), must be a valid variable name", name)
+	}
+	return nil
+}
+
+func encryptSecret(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed) + secretSuffix, nil
+}
+
+func decryptSecret(key []byte, s string) (string, error) {
+	if !strings.HasPrefix(s, secretPrefix) || !strings.HasSuffix(s, secretSuffix) {
+		return "", errors.New("not an encrypted value")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(s, secretPrefix), secretSuffix))
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted value too short")
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("decrypt failed, the secret key may be wrong")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// HasSecrets returns whether the zone has the secrets file.
+func (zone *Zone) HasSecrets() bool {
+	_, err := os.Stat(zone.SecretsFile)
+	return err == nil
+}
+
+// readEncryptedSecrets returns the encrypted secrets in the secrets file of the zone.
+func (zone *Zone) readEncryptedSecrets() (map[string]string, error) {
+	m := make(map[string]string)
+
+	b, err := os.ReadFile(zone.SecretsFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, fmt.Errorf("read secrets file failed, err: %s", err)
+	}
+
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("unmarshal secrets file failed, err: %s", err)
+	}
+	return m, nil
+}
+
+func (zone *Zone) writeEncryptedSecrets(m map[string]string) error {
+	b, err := common.Encode("yaml", m)
+	if err != nil {
+		return fmt.Errorf("encode secrets failed, err: %s", err)
+	}
+
+	if err := os.MkdirAll(zone.ZoneDir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(zone.SecretsFile, append([]byte(secretsFileHeader), b...), 0600); err != nil {
+		return fmt.Errorf("write secrets file failed, err: %s", err)
+	}
+	return nil
+}
+
+// SecretNames returns the names of the secrets of the zone, no key is needed.
+func (zone *Zone) SecretNames() ([]string, error) {
+	m, err := zone.readEncryptedSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// LoadSecrets decrypts all secrets of the zone in memory.
+// The decrypted secrets are also masked in the output of the runs of the zone.
+func (zone *Zone) LoadSecrets(key []byte) (map[string]string, error) {
+	m, err := zone.readEncryptedSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string)
+	for name, encrypted := range m {
+		v, err := decryptSecret(key, encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt secret (%s) failed, err: %s", name, err)
+		}
+		out[name] = v
+	}
+
+	zone.secrets = out
+	return out, nil
+}
+
+// GetSecret returns the decrypted value of the secret.
+func (zone *Zone) GetSecret(key []byte, name string) (string, error) {
+	m, err := zone.readEncryptedSecrets()
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, ok := m[name]
+	if !ok {
+		return "", fmt.Errorf("not found secret (%s) in zone", name)
+	}
+
+	v, err := decryptSecret(key, encrypted)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret (%s) failed, err: %s", name, err)
+	}
+	return v, nil
+}
+
+// SetSecret encrypts the value and saves it as the secret of the zone.
+// The existing secrets must be decryptable by the key, to avoid mixing up keys in one zone.
+func (zone *Zone) SetSecret(key []byte, name string, value string) error {
+	if err := ValidateSecretName(name); err != nil {
+		return err
+	}
+
+	if _, err := zone.LoadSecrets(key); err != nil {
+		return err
+	}
+
+	m, err := zone.readEncryptedSecrets()
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := encryptSecret(key, value)
+	if err != nil {
+		return fmt.Errorf("encrypt secret (%s) failed, err: %s", name, err)
+	}
+	m[name] = encrypted
+
+	return zone.writeEncryptedSecrets(m)
+}
+
+// RotateSecrets re-encrypts all secrets of the zone with the new key.
+func (zone *Zone) RotateSecrets(oldKey []byte, newKey []byte) error {
+	secrets, err := zone.LoadSecrets(oldKey)
+	if err != nil {
+		return err
+	}
+
+	m := make(map[string]string)
+	for name, v := range secrets {
+		encrypted, err := encryptSecret(newKey, v)
+		if err != nil {
+			return fmt.Errorf("encrypt secret (%s) failed, err: %s", name, err)
+		}
+		m[name] = encrypted
+	}
+
+	return zone.writeEncryptedSecrets(m)
+}
+
+// WriteSecretsTempFile writes the decrypted secrets into a temporary file readable only by the current user,
+// which is passed to ansible-playbook by `-e @file` and helm by `--values file`.
+// The returned function removes the file, it MUST be called after the run.
+func WriteSecretsTempFile(secrets map[string]string) (string, func(), error) {
+	b, err := common.Encode("yaml", secrets)
+	if err != nil {
+		return "", nil, fmt.Errorf("encode secrets failed, err: %s", err)
+	}
+
+	return writeSecretTempFile("sail-secrets-*.yaml", b)
+}
+
+// writeSecretTempFile writes the sensitive content into a temporary file readable only by the current user.
+// The file is created under /dev/shm (memory backed) if available, so the content never hits the disk.
+// The returned function removes the file, it is also called if sail is interrupted or terminated
+// while the zone is running (see watchSignals).
+func writeSecretTempFile(pattern string, b []byte) (string, func(), error) {
+	dir := os.TempDir()
+	if stat, err := os.Stat("/dev/shm"); err == nil && stat.IsDir() {
+		dir = "/dev/shm"
+	}
+
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("create temp file failed, err: %s", err)
+	}
+	name := path.Clean(f.Name())
+	cleanup := func() {
+		_ = os.Remove(name)
+	}
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return name, cleanup, nil
+}
@@ -0,0 +1,138 @@
+package target
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	key := sha256.Sum256([]byte("k1"))
+	wrongKey := sha256.Sum256([]byte("k2"))
+
+	s, err := encryptSecret(key[:], "s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(s, "ENC[AES256_GCM,") || strings.Contains(s, "s3cr3t") {
+		t.Errorf("unexpected encrypted secret: %s", s)
+	}
+
+	v, err := decryptSecret(key[:], s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "s3cr3t" {
+		t.Errorf("expected s3cr3t, got %s", v)
+	}
+
+	if _, err := decryptSecret(wrongKey[:], s); err == nil {
+		t.Error("expected error when decrypting with wrong key")
+	}
+}
+
+func TestParseSecretKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{0xab}, 32)
+
+	for _, material := range []string{base64.StdEncoding.EncodeToString(raw), hex.EncodeToString(raw)} {
+		key, err := parseSecretKey(material)
+		if err != nil {
+			t.Fatalf("parse key (%s) failed, err: %s", material, err)
+		}
+		if !bytes.Equal(key, raw) {
+			t.Errorf("unexpected key parsed from (%s)", material)
+		}
+	}
+
+	for _, material := range []string{"my passphrase", base64.StdEncoding.EncodeToString(raw[:16])} {
+		if _, err := parseSecretKey(material); err == nil {
+			t.Errorf("expected error for key (%s)", material)
+		}
+	}
+}
+
+func TestZone_SetSecret(t *testing.T) {
+	zone := newTestZone(t, newTestSailOption(t), "t1", "z1", "", "")
+	key := sha256.Sum256([]byte("k1"))
+	newKey := sha256.Sum256([]byte("k2"))
+
+	if err := zone.SetSecret(key[:], "db-pass", "v"); err == nil {
+		t.Error("expected error for invalid secret name")
+	}
+	if err := zone.SetSecret(key[:], "db_pass", "s3cr3t-1"); err != nil {
+		t.Fatal(err)
+	}
+	// the existing secrets must be decryptable by the key
+	if err := zone.SetSecret(newKey[:], "api_token", "s3cr3t-2"); err == nil {
+		t.Error("expected error for setting secret with another key")
+	}
+	if err := zone.SetSecret(key[:], "api_token", "s3cr3t-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(zone.SecretsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "s3cr3t") {
+		t.Errorf("found the plain text secret in secrets file:\n%s", b)
+	}
+	if stat, err := os.Stat(zone.SecretsFile); err != nil || stat.Mode().Perm() != 0600 {
+		t.Errorf("expected secrets file mode 0600, got %v", stat.Mode().Perm())
+	}
+
+	names, err := zone.SecretNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "api_token,db_pass" {
+		t.Errorf("unexpected secret names %v", names)
+	}
+
+	if err := zone.RotateSecrets(newKey[:], key[:]); err == nil {
+		t.Error("expected error for rotating with wrong old key")
+	}
+	if err := zone.RotateSecrets(key[:], newKey[:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zone.GetSecret(key[:], "db_pass"); err == nil {
+		t.Error("expected error for getting secret with the old key after rotation")
+	}
+	v, err := zone.GetSecret(newKey[:], "db_pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "s3cr3t-1" {
+		t.Errorf("expected s3cr3t-1, got %s", v)
+	}
+}
+
+func TestZone_MaskSecrets(t *testing.T) {
+	zone := newTestZone(t, newTestSailOption(t), "t1", "z1", "foobar-api:\n  vars:\n    db_password: p4ssw0rd\n", "")
+	key := sha256.Sum256([]byte("k1"))
+	if err := zone.SetSecret(key[:], "api_token", "t0ken-value"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zone.LoadSecrets(key[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	w := zone.MaskSecrets(buf)
+	w.Write([]byte("connect with p4ssw0rd\ncurl -H 't0ken-value'\nsecret=abc123"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "connect with ******\ncurl -H '******'\nsecret=******"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	if s := zone.MaskSecretsString("+  db_password: p4ssw0rd\n"); strings.Contains(s, "p4ssw0rd") {
+		t.Errorf("found the secret in masked string: %s", s)
+	}
+}
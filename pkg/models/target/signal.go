@@ -0,0 +1,63 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var errInterrupted = errors.New("sail is interrupted")
+
+// runSignals cancels the commands of all the running zones once sail is interrupted or terminated,
+// so the running zones return through their normal path, and their deferred cleanups
+// (eg: removing the temporary files of the secrets, saving the run records) are not skipped.
+// The signals are only caught while any zone is running, and only the first signal is caught,
+// the second one terminates sail as usual.
+var runSignals = struct {
+	sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running int
+	done    chan struct{}
+}{}
+
+func init() {
+	runSignals.ctx, runSignals.cancel = context.WithCancel(context.Background())
+}
+
+// watchSignals returns the context which is canceled once sail is interrupted or terminated.
+// The returned function MUST be called once the zone finished running.
+func watchSignals() (context.Context, func()) {
+	runSignals.Lock()
+	defer runSignals.Unlock()
+
+	runSignals.running++
+	if runSignals.running == 1 {
+		ch := make(chan os.Signal, 1)
+		done := make(chan struct{})
+		runSignals.done = done
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		go func() {
+			defer signal.Stop(ch)
+			select {
+			case <-ch:
+				runSignals.cancel()
+			case <-done:
+			}
+		}()
+	}
+
+	release := func() {
+		runSignals.Lock()
+		defer runSignals.Unlock()
+
+		runSignals.running--
+		if runSignals.running == 0 {
+			close(runSignals.done)
+		}
+	}
+	return runSignals.ctx, release
+}
@@ -0,0 +1,204 @@
+package target
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/bougou/sail/pkg/ansible"
+	"github.com/bougou/sail/pkg/models/product"
+	"gopkg.in/yaml.v3"
+)
+
+// ZoneSummary is the overview of a zone.
+type ZoneSummary struct {
+	Target            string    `json:"target" yaml:"target"`
+	Zone              string    `json:"zone" yaml:"zone"`
+	Product           string    `json:"product" yaml:"product"`
+	HelmMode          string    `json:"helmMode" yaml:"helmMode"`
+	Labels            Labels    `json:"labels" yaml:"labels"`
+	EnabledComponents int       `json:"enabledComponents" yaml:"enabledComponents"`
+	Hosts             int       `json:"hosts" yaml:"hosts"`
+	ModifiedAt        time.Time `json:"modifiedAt" yaml:"modifiedAt"`
+
+	// InventorySources is true if the zone declares inventory sources, their hosts are not counted in Hosts
+	InventorySources bool `json:"inventorySources" yaml:"inventorySources"`
+
+	// Error is set if the zone can not be loaded
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+
+	// the static hosts of the zone
+	hosts []string
+}
+
+// Summary returns the overview of the zone.
+// Only the zone meta, the components in the zone vars file and the static hosts in hosts.yaml are read,
+// the files encrypted by ansible-vault are not decrypted and the inventory sources are not resolved.
+// A broken zone is reported in the Error field of the summary.
+func (zone *Zone) Summary() *ZoneSummary {
+	s := &ZoneSummary{
+		Target:     zone.TargetName,
+		Zone:       zone.ZoneName,
+		Labels:     Labels{},
+		ModifiedAt: zone.ModifiedAt(),
+	}
+
+	if err := zone.summarize(s); err != nil {
+		s.Error = err.Error()
+	}
+
+	return s
+}
+
+func (zone *Zone) summarize(s *ZoneSummary) error {
+	b, err := os.ReadFile(zone.VarsFile)
+	if err != nil {
+		return fmt.Errorf("read zone vars file failed, err: %s", err)
+	}
+	if ansible.IsVaulted(b) {
+		return errors.New("the zone vars file is encrypted by ansible-vault")
+	}
+
+	m := &ZoneMeta{}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return fmt.Errorf("parse zone meta failed, err: %s", err)
+	}
+	if m.SailProduct == "" {
+		return fmt.Errorf("not found (%s) variable in %s", SailMetaVarProduct, zone.VarsFile)
+	}
+	s.Product = m.SailProduct
+	s.HelmMode = m.SailHelmMode
+	if m.SailLabels != nil {
+		s.Labels = m.SailLabels
+	}
+
+	p := product.NewProduct(m.SailProduct, zone.sailOption.ProductsDir)
+	if err := p.InitComponents(); err != nil {
+		return fmt.Errorf("init product failed, err: %s", err)
+	}
+	if err := p.LoadZoneVars(b); err != nil {
+		return fmt.Errorf("load zone vars failed, err: %s", err)
+	}
+	for _, c := range p.Components {
+		if c.Enabled {
+			s.EnabledComponents++
+		}
+	}
+
+	b, err = os.ReadFile(zone.HostsFile)
+	if err != nil {
+		return fmt.Errorf("read file (%s) failed, err: %s", zone.HostsFile, err)
+	}
+	if ansible.IsVaulted(b) {
+		return errors.New("the zone hosts file is encrypted by ansible-vault")
+	}
+	i := ansible.NewAnsibleInventory()
+	if err := yaml.Unmarshal(b, i); err != nil {
+		return fmt.Errorf("unmarshal hosts failed, err: %s", err)
+	}
+
+	hosts := make(map[string]bool)
+	for _, host := range i.GetAllHosts() {
+		hosts[host] = true
+	}
+	s.hosts = sortedKeys(hosts)
+	s.Hosts = len(s.hosts)
+
+	sources, err := ParseInventorySources(i)
+	if err != nil {
+		return err
+	}
+	s.InventorySources = len(sources) != 0
+
+	return nil
+}
+
+// ModifiedAt returns the last modification time of the zone files.
+func (zone *Zone) ModifiedAt() time.Time {
+	var t time.Time
+	for _, f := range []string{zone.VarsFile, zone.HostsFile, zone.PlatformsFile, zone.ComputedFile} {
+		stat, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if stat.ModTime().After(t) {
+			t = stat.ModTime()
+		}
+	}
+	return t
+}
+
+// TargetSummary is the overview of a target.
+type TargetSummary struct {
+	Target            string    `json:"target" yaml:"target"`
+	Zones             int       `json:"zones" yaml:"zones"`
+	Products          []string  `json:"products" yaml:"products"`
+	HelmModes         []string  `json:"helmModes" yaml:"helmModes"`
+	EnabledComponents int       `json:"enabledComponents" yaml:"enabledComponents"`
+	Hosts             int       `json:"hosts" yaml:"hosts"`
+	ModifiedAt        time.Time `json:"modifiedAt" yaml:"modifiedAt"`
+
+	// InventorySourceZones holds the zones which declare inventory sources, their hosts are not counted in Hosts
+	InventorySourceZones []string `json:"inventorySourceZones,omitempty" yaml:"inventorySourceZones,omitempty"`
+
+	// Errors holds the errors of the zones which can not be loaded
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// Summary returns the overview of the target, which sums up the summaries of its zones.
+// The hosts shared by multiple zones are counted once.
+func (t *Target) Summary() (*TargetSummary, error) {
+	zoneNames, err := t.AllZones()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &TargetSummary{
+		Target:    t.Name,
+		Zones:     len(zoneNames),
+		Products:  []string{},
+		HelmModes: []string{},
+	}
+
+	products := make(map[string]bool)
+	helmModes := make(map[string]bool)
+	hosts := make(map[string]bool)
+	for _, zoneName := range zoneNames {
+		zone := NewZone(t.sailOption, t.Name, zoneName)
+		zs := zone.Summary()
+		if zs.Error != "" {
+			s.Errors = append(s.Errors, zoneName+": "+zs.Error)
+			continue
+		}
+
+		products[zs.Product] = true
+		helmModes[zs.HelmMode] = true
+		s.EnabledComponents += zs.EnabledComponents
+		for _, host := range zs.hosts {
+			hosts[host] = true
+		}
+		if zs.InventorySources {
+			s.InventorySourceZones = append(s.InventorySourceZones, zoneName)
+		}
+		if zs.ModifiedAt.After(s.ModifiedAt) {
+			s.ModifiedAt = zs.ModifiedAt
+		}
+	}
+
+	s.Products = sortedKeys(products)
+	s.HelmModes = sortedKeys(helmModes)
+	s.Hosts = len(hosts)
+
+	return s, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := []string{}
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
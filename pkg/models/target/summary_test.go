@@ -0,0 +1,79 @@
+package target
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	sailOption := newTestSailOption(t)
+	targetDir := path.Join(sailOption.TargetsDir, "t1")
+
+	writeTestFiles(t, targetDir, map[string]string{
+		"z1/vars.yaml":  "_sail_product: foobar\n_sail_helm_mode: component\n_sail_labels:\n  tier: canary\n",
+		"z1/hosts.yaml": "foobar-api:\n  hosts:\n    10.0.0.1: {}\n    10.0.0.2: {}\n",
+		// the inventory sources are not resolved, the script does not exist
+		"z2/vars.yaml": "_sail_product: foobar\nfoobar-api:\n  enabled: false\n",
+		"z2/hosts.yaml": `all:
+  vars:
+    _sail_inventory_sources:
+      - type: script
+        path: not-exist.sh
+foobar-api:
+  hosts:
+    10.0.0.2: {}
+    10.0.0.3: {}
+`,
+		"z3/vars.yaml":  "foobar-api:\n  enabled: true\n",
+		"z3/hosts.yaml": "",
+		// the vaulted files are not decrypted, no vault password is specified
+		"z4/vars.yaml":  "$ANSIBLE_VAULT;1.1;AES256\n6162630a\n",
+		"z4/hosts.yaml": "",
+	})
+
+	z1 := NewZone(sailOption, "t1", "z1").Summary()
+	expected := &ZoneSummary{
+		Target:            "t1",
+		Zone:              "z1",
+		Product:           "foobar",
+		HelmMode:          "component",
+		Labels:            Labels{"tier": "canary"},
+		EnabledComponents: 1,
+		Hosts:             2,
+		ModifiedAt:        z1.ModifiedAt,
+		hosts:             []string{"10.0.0.1", "10.0.0.2"},
+	}
+	if !reflect.DeepEqual(z1, expected) {
+		t.Errorf("expected summary %+v, got %+v", expected, z1)
+	}
+	if z1.ModifiedAt.IsZero() {
+		t.Error("expected the modified time of zone files")
+	}
+
+	z2 := NewZone(sailOption, "t1", "z2").Summary()
+	if z2.Error != "" || z2.EnabledComponents != 0 || z2.Hosts != 2 || !z2.InventorySources {
+		t.Errorf("unexpected summary %+v", z2)
+	}
+
+	for _, zoneName := range []string{"z3", "z4"} {
+		if s := NewZone(sailOption, "t1", zoneName).Summary(); s.Error == "" {
+			t.Errorf("expected error in summary of zone (%s)", zoneName)
+		}
+	}
+
+	s, err := NewTarget(sailOption, "t1").Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Zones != 4 || s.EnabledComponents != 1 || s.Hosts != 3 || len(s.Errors) != 2 {
+		t.Errorf("unexpected target summary %+v", s)
+	}
+	if strings.Join(s.InventorySourceZones, ",") != "z2" {
+		t.Errorf("expected zones with inventory sources [z2], got %v", s.InventorySourceZones)
+	}
+	if strings.Join(s.Products, ",") != "foobar" || strings.Join(s.HelmModes, ",") != ",component" {
+		t.Errorf("unexpected products %v and helm modes %v", s.Products, s.HelmModes)
+	}
+}
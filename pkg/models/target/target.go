@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
+	"sync"
 
 	"github.com/bougou/sail/pkg/models"
 )
@@ -18,6 +20,9 @@ type Target struct {
 
 type TargetVars struct {
 	Zones map[string]interface{}
+
+	// any zone of the target has files encrypted by ansible-vault as a whole
+	vaulted bool
 }
 
 func NewTargetVars() *TargetVars {
@@ -36,6 +41,24 @@ func NewTarget(sailOption *models.SailOption, name string) *Target {
 	}
 }
 
+// AllTargets returns the names of the targets under the targets dir.
+func AllTargets(sailOption *models.SailOption) ([]string, error) {
+	entries, err := os.ReadDir(sailOption.TargetsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	targetNames := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		targetNames = append(targetNames, entry.Name())
+	}
+
+	return targetNames, nil
+}
+
 // AllZones return zone names list of the target.
 func (t *Target) AllZones() ([]string, error) {
 	entries, err := os.ReadDir(t.dir)
@@ -61,6 +84,56 @@ func (t *Target) AllZones() ([]string, error) {
 	return zoneNames, nil
 }
 
+// ValidateZoneGlob returns error if the zones pattern is not a valid shell glob (eg: 'prod-*').
+func ValidateZoneGlob(glob string) error {
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("invalid zones pattern (%s), err: %s", glob, err)
+	}
+	return nil
+}
+
+// MatchZoneGlobs returns whether the zone name matches any of the shell globs.
+func MatchZoneGlobs(globs []string, zoneName string) bool {
+	for _, glob := range globs {
+		if matched, _ := path.Match(glob, zoneName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// targetVarsCache caches the loaded targetvars of the targets in the process, keyed by the target dir.
+var targetVarsCache = struct {
+	sync.Mutex
+	m map[string]*cachedTargetVars
+}{m: make(map[string]*cachedTargetVars)}
+
+type cachedTargetVars struct {
+	once sync.Once
+	vars *TargetVars
+	err  error
+}
+
+// loadTargetVars loads the vars of all zones of the target at most once in the process.
+// The returned TargetVars is shared, it MUST NOT be modified.
+func loadTargetVars(sailOption *models.SailOption, targetName string) (*TargetVars, error) {
+	t := NewTarget(sailOption, targetName)
+
+	targetVarsCache.Lock()
+	c, ok := targetVarsCache.m[t.dir]
+	if !ok {
+		c = &cachedTargetVars{}
+		targetVarsCache.m[t.dir] = c
+	}
+	targetVarsCache.Unlock()
+
+	c.once.Do(func() {
+		c.err = t.LoadAllZones()
+		c.vars = t.vars
+	})
+	return c.vars, c.err
+}
+
 func (t *Target) LoadAllZones() error {
 	zoneNames, err := t.AllZones()
 	if err != nil {
@@ -77,7 +150,8 @@ func (t *Target) LoadAllZones() error {
 
 func (t *Target) LoadZone(zoneName string) error {
 	zone := NewZone(t.sailOption, t.Name, zoneName)
-	if err := zone.Load(); err != nil {
+	// only the vars of the zone are needed, no need to prepare helm charts
+	if err := zone.LoadConf(); err != nil {
 		return fmt.Errorf("load zone (%s) failed, err: %s", zoneName, err)
 	}
 
@@ -96,6 +170,9 @@ func (t *Target) LoadZone(zoneName string) error {
 	zoneV["inventory"] = zone.CMDB.Inventory
 
 	t.vars.Zones[zoneName] = zoneV
+	if zone.vaultedSources {
+		t.vars.vaulted = true
+	}
 	return nil
 }
 
@@ -0,0 +1,212 @@
+package target
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/bougou/gopkg/common"
+	"github.com/bougou/sail/pkg/ansible"
+	"gopkg.in/yaml.v3"
+)
+
+// VaultArgs returns the ansible-vault options (--vault-password-file and --vault-id) passed to sail,
+// which are passed through to ansible-playbook and ansible-vault.
+func (zone *Zone) VaultArgs() []string {
+	args := []string{}
+	if zone.sailOption.VaultPasswordFile != "" {
+		args = append(args, "--vault-password-file", zone.sailOption.VaultPasswordFile)
+	}
+	for _, vaultID := range zone.sailOption.VaultIDs {
+		args = append(args, "--vault-id", vaultID)
+	}
+	return args
+}
+
+// readZoneFile reads the zone file.
+// The file encrypted by ansible-vault as a whole is decrypted in memory,
+// and the files derived from it are encrypted too, see encryptDerived.
+func (zone *Zone) readZoneFile(name string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ansible.IsVaulted(b) {
+		return b, nil
+	}
+
+	plain, err := zone.runAnsibleVault("decrypt", b)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt file (%s) failed, err: %s", name, err)
+	}
+	zone.vaultedSources = true
+	return plain, nil
+}
+
+// encryptDerived returns whether the files derived from the zone files (_computed.yaml and _inventory.yaml)
+// must be encrypted by ansible-vault, that is, any file of the zone or of the other zones of the target
+// is encrypted as a whole, whose decrypted values are copied into the derived files.
+func (zone *Zone) encryptDerived() bool {
+	return zone.vaultedSources || (zone.TargetVars != nil && zone.TargetVars.vaulted)
+}
+
+// writeZoneFile writes the zone file, a snapshot is taken before the zone files are overwritten.
+// If encrypt is true or the existing file is encrypted by ansible-vault as a whole, the content is encrypted before writing,
+// and the encrypted file is not rewritten if the content is not changed, to keep the file stable in version control.
+func (zone *Zone) writeZoneFile(name string, b []byte, encrypt bool) error {
+	old, err := os.ReadFile(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	oldPlain := old
+	if ansible.IsVaulted(old) {
+		oldPlain, err = zone.runAnsibleVault("decrypt", old)
+		if err != nil {
+			return fmt.Errorf("decrypt file (%s) failed, err: %s", name, err)
+		}
+		if bytes.Equal(oldPlain, b) {
+			return nil
+		}
+		encrypt = true
+	}
+
+	if err := zone.snapshotBeforeWrite(name, oldPlain, b); err != nil {
+		return err
+	}
+
+	if encrypt {
+		b, err = zone.runAnsibleVault("encrypt", b)
+		if err != nil {
+			return fmt.Errorf("encrypt file (%s) failed, err: %s", name, err)
+		}
+	}
+
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(name); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	// write atomically, the zone files may be read by the other zones run at the same time
+	return writeFileAtomic(name, b, perm)
+}
+
+// runAnsibleVault encrypts or decrypts the input by ansible-vault with the vault options passed to sail.
+func (zone *Zone) runAnsibleVault(action string, input []byte) ([]byte, error) {
+	vaultArgs := zone.VaultArgs()
+	if len(vaultArgs) == 0 {
+		return nil, errors.New("the content is encrypted by ansible-vault, specify the '--vault-password-file' or '--vault-id' option")
+	}
+
+	args := []string{action, "--output", "-"}
+	// ansible-vault requires to choose the vault id to encrypt with when multiple vault ids specified
+	if action == "encrypt" && len(zone.sailOption.VaultIDs) > 1 {
+		args = append(args, "--encrypt-vault-id", vaultIDLabel(zone.sailOption.VaultIDs[0]))
+	}
+	args = append(args, vaultArgs...)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command("ansible-vault", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ansible-vault %s failed, err: %s, %s", action, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// vaultIDLabel returns the label of the vault id in the form of `label@source`.
+func vaultIDLabel(vaultID string) string {
+	if i := strings.Index(vaultID, "@"); i >= 0 {
+		return vaultID[:i]
+	}
+	return "default"
+}
+
+// HelmValuesFile returns the zone file (vars.yaml or _computed.yaml) passed to helm by `--values`.
+// helm can not decrypt the content encrypted by ansible-vault, so if the file
+// or any value in it is encrypted, a decrypted copy is written to a temporary file readable only by the current user.
+// The returned function removes the temporary file, it MUST be called after the run.
+func (zone *Zone) HelmValuesFile(name string) (string, func(), error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("read file (%s) failed, err: %s", name, err)
+	}
+	if !bytes.Contains(b, []byte(ansible.VaultHeader)) {
+		return name, func() {}, nil
+	}
+
+	b, err = zone.readZoneFile(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return "", nil, fmt.Errorf("unmarshal file (%s) failed, err: %s", name, err)
+	}
+	if err := zone.decryptVaultedValues(m); err != nil {
+		return "", nil, err
+	}
+
+	b, err = common.Encode("yaml", m)
+	if err != nil {
+		return "", nil, fmt.Errorf("encode file (%s) failed, err: %s", name, err)
+	}
+
+	return writeSecretTempFile("sail-"+strings.TrimSuffix(path.Base(name), ".yaml")+"-*.yaml", b)
+}
+
+// decryptVaultedValues decrypts the values encrypted by ansible-vault in place.
+func (zone *Zone) decryptVaultedValues(v interface{}) error {
+	decrypt := func(s string) (interface{}, error) {
+		if !strings.HasPrefix(s, ansible.VaultHeader) {
+			return s, nil
+		}
+		b, err := zone.runAnsibleVault("decrypt", []byte(s))
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, item := range vv {
+			if s, ok := item.(string); ok {
+				d, err := decrypt(s)
+				if err != nil {
+					return fmt.Errorf("decrypt value of (%s) failed, err: %s", k, err)
+				}
+				vv[k] = d
+				continue
+			}
+			if err := zone.decryptVaultedValues(item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range vv {
+			if s, ok := item.(string); ok {
+				d, err := decrypt(s)
+				if err != nil {
+					return fmt.Errorf("decrypt value failed, err: %s", err)
+				}
+				vv[i] = d
+				continue
+			}
+			if err := zone.decryptVaultedValues(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package target
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bougou/gopkg/common"
+)
+
+// StagedComponents returns the names of the components whose versions are staged but not committed.
+func (zone *Zone) StagedComponents() []string {
+	out := []string{}
+	for componentName := range zone.stagedVersions {
+		out = append(out, componentName)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// EffectiveVersion returns the version of the component in the zone vars file,
+// which differs from the version in memory if the version of the component is staged.
+func (zone *Zone) EffectiveVersion(componentName string) string {
+	if version, staged := zone.stagedVersions[componentName]; staged {
+		return version
+	}
+	if c, ok := zone.Product.Components[componentName]; ok {
+		return c.Version
+	}
+	return ""
+}
+
+// CommitComponentVersion marks the staged version of the component as effective,
+// it is written to the zone vars file by the next RenderVars or Dump.
+func (zone *Zone) CommitComponentVersion(componentName string) {
+	delete(zone.stagedVersions, componentName)
+}
+
+// writeStagedFile writes the staged components into a temporary file,
+// which is passed to ansible-playbook by `-e @file` and helm by `--values file` to override the zone vars file.
+// The returned function removes the file, it MUST be called after the run.
+func (zone *Zone) writeStagedFile() (string, func(), error) {
+	m := make(map[string]interface{})
+	for _, componentName := range zone.StagedComponents() {
+		m[componentName] = zone.Product.Components[componentName]
+	}
+
+	b, err := common.Encode("yaml", m)
+	if err != nil {
+		return "", nil, fmt.Errorf("encode staged components failed, err: %s", err)
+	}
+
+	// the components may contain secrets in their vars
+	return writeSecretTempFile("sail-staged-*.yaml", b)
+}
@@ -0,0 +1,108 @@
+package target
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestZone_StagedVersion(t *testing.T) {
+	zone := newTestZone(t, newTestSailOption(t), "t1", "z1", "foobar-api:\n  version: v1.0.0\n", "")
+
+	// the version in the zone vars file
+	varsFileVersion := func() string {
+		b, err := zone.encodeVars()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := struct {
+			Component struct {
+				Version string `yaml:"version"`
+			} `yaml:"foobar-api"`
+		}{}
+		if err := yaml.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		return m.Component.Version
+	}
+
+	if err := zone.SetComponentVersion("not-exist", "v1.1.0"); err == nil {
+		t.Error("expected error for the component not exists")
+	}
+
+	// setting the same version stages nothing
+	if err := zone.SetComponentVersion("foobar-api", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.StagedComponents()) != 0 {
+		t.Errorf("expected no staged components, got %v", zone.StagedComponents())
+	}
+
+	// the previous version is kept when staged multiple times
+	for _, version := range []string{"v1.1.0", "v1.2.0"} {
+		if err := zone.SetComponentVersion("foobar-api", version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if staged := zone.StagedComponents(); strings.Join(staged, ",") != "foobar-api" {
+		t.Errorf("expected staged components [foobar-api], got %v", staged)
+	}
+	if v := zone.Product.Components["foobar-api"].Version; v != "v1.2.0" {
+		t.Errorf("expected version v1.2.0 in memory, got %s", v)
+	}
+	if v := zone.EffectiveVersion("foobar-api"); v != "v1.0.0" {
+		t.Errorf("expected effective version v1.0.0, got %s", v)
+	}
+	if v := varsFileVersion(); v != "v1.0.0" {
+		t.Errorf("expected the staged version not written to vars file, got %s", v)
+	}
+	// the staged component is not changed by encoding the vars
+	if v := zone.Product.Components["foobar-api"].Version; v != "v1.2.0" {
+		t.Errorf("expected version v1.2.0 in memory after encoding, got %s", v)
+	}
+
+	zone.CommitComponentVersion("foobar-api")
+	if len(zone.StagedComponents()) != 0 {
+		t.Errorf("expected no staged components after commit, got %v", zone.StagedComponents())
+	}
+	if v := zone.EffectiveVersion("foobar-api"); v != "v1.2.0" {
+		t.Errorf("expected effective version v1.2.0 after commit, got %s", v)
+	}
+	if v := varsFileVersion(); v != "v1.2.0" {
+		t.Errorf("expected the committed version written to vars file, got %s", v)
+	}
+}
+
+func TestRunningZone_CommitVersions(t *testing.T) {
+	zone := newTestZone(t, newTestSailOption(t), "t1", "z1", "foobar-api:\n  version: v1.0.0\n", "")
+	if err := zone.SetComponentVersion("foobar-api", "v1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	rz := NewRunningZone(zone, "")
+	rz.record = &RunRecord{Results: []*ComponentResult{{Component: "foobar-api", Version: "v1.1.0", Success: true}}}
+	rz.commitVersions()
+
+	if v := rz.record.Results[0].EffectiveVersion; v != "v1.1.0" {
+		t.Errorf("expected effective version v1.1.0, got %s", v)
+	}
+
+	b, err := os.ReadFile(zone.VarsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "v1.1.0") {
+		t.Errorf("expected the committed version written to vars file, got:\n%s", b)
+	}
+
+	// the vars file before committing is saved
+	snapshots, err := zone.Histories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 {
+		t.Errorf("expected 1 snapshot, got %d", len(snapshots))
+	}
+}
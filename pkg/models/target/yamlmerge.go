@@ -0,0 +1,159 @@
+package target
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bougou/gopkg/common"
+	"gopkg.in/yaml.v3"
+)
+
+// encodeYAMLKeepingLayout encodes v to yaml like common.Encode, but keeps the comments, the key order and
+// the styles of the old yaml content. The updated values are written in place, the removed keys are dropped,
+// and the new keys are inserted right after their preceding keys in v.
+// The top level keys of v are ordered by order (eg: the key order of the product vars.yaml) before merging,
+// the keys not in order follow in their sorted order.
+// It falls back to common.Encode if the old content is empty or can not be parsed.
+func encodeYAMLKeepingLayout(old []byte, v interface{}, order []string) ([]byte, error) {
+	newNode := &yaml.Node{}
+	if err := newNode.Encode(v); err != nil {
+		return nil, err
+	}
+	sortYAMLMapping(newNode, order)
+
+	oldDoc := &yaml.Node{}
+	if err := yaml.Unmarshal(old, oldDoc); err != nil || oldDoc.Kind != yaml.DocumentNode || len(oldDoc.Content) == 0 {
+		return common.Encode("yaml", v)
+	}
+
+	oldDoc.Content[0] = mergeYAMLNode(oldDoc.Content[0], newNode)
+
+	b, err := common.Encode("yaml", oldDoc)
+	if err != nil {
+		return nil, fmt.Errorf("encode yaml node failed, err: %s", err)
+	}
+	return b, nil
+}
+
+// mergeYAMLNode returns the node which has the content of newNode, and the layout of oldNode.
+func mergeYAMLNode(oldNode *yaml.Node, newNode *yaml.Node) *yaml.Node {
+	if oldNode.Kind != newNode.Kind || oldNode.ShortTag() != newNode.ShortTag() {
+		copyYAMLComments(newNode, oldNode)
+		return newNode
+	}
+
+	switch oldNode.Kind {
+	case yaml.ScalarNode:
+		// keep the quoting style of the unchanged values, and the null values written in any form (`~`, `null` or empty)
+		if oldNode.Value == newNode.Value || oldNode.ShortTag() == "!!null" {
+			return oldNode
+		}
+		copyYAMLComments(newNode, oldNode)
+		return newNode
+
+	case yaml.MappingNode:
+		mergeYAMLMapping(oldNode, newNode)
+
+	case yaml.SequenceNode:
+		content := []*yaml.Node{}
+		for i, item := range newNode.Content {
+			if i < len(oldNode.Content) {
+				item = mergeYAMLNode(oldNode.Content[i], item)
+			}
+			content = append(content, item)
+		}
+		oldNode.Content = content
+
+	default:
+		copyYAMLComments(newNode, oldNode)
+		return newNode
+	}
+
+	// the empty collection is written in flow style like `{}`, use the style of the new node once it has items
+	if oldNode.Style&yaml.FlowStyle != 0 && newNode.Style&yaml.FlowStyle == 0 {
+		oldNode.Style = newNode.Style
+	}
+
+	return oldNode
+}
+
+// mergeYAMLMapping merges the keys of newNode into oldNode in place.
+func mergeYAMLMapping(oldNode *yaml.Node, newNode *yaml.Node) {
+	newValues := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(newNode.Content); i += 2 {
+		newValues[newNode.Content[i].Value] = newNode.Content[i+1]
+	}
+
+	// keep the existing keys in their order, and drop the removed keys
+	content := []*yaml.Node{}
+	for i := 0; i+1 < len(oldNode.Content); i += 2 {
+		key, value := oldNode.Content[i], oldNode.Content[i+1]
+		newValue, ok := newValues[key.Value]
+		if !ok {
+			continue
+		}
+		content = append(content, key, mergeYAMLNode(value, newValue))
+	}
+
+	// insert the new keys right after their preceding keys
+	pos := 0
+	for i := 0; i+1 < len(newNode.Content); i += 2 {
+		key, value := newNode.Content[i], newNode.Content[i+1]
+		if j := indexOfYAMLKey(content, key.Value); j >= 0 {
+			pos = j + 2
+			continue
+		}
+		content = append(content[:pos], append([]*yaml.Node{key, value}, content[pos:]...)...)
+		pos += 2
+	}
+
+	oldNode.Content = content
+}
+
+// sortYAMLMapping sorts the keys of the mapping node by their index in order,
+// the keys not in order are moved to the end and keep their relative order.
+func sortYAMLMapping(node *yaml.Node, order []string) {
+	if node.Kind != yaml.MappingNode || len(order) == 0 {
+		return
+	}
+
+	rank := make(map[string]int)
+	for i, key := range order {
+		rank[key] = i
+	}
+	rankOf := func(key string) int {
+		if r, ok := rank[key]; ok {
+			return r
+		}
+		return len(order)
+	}
+
+	pairs := [][2]*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return rankOf(pairs[i][0].Value) < rankOf(pairs[j][0].Value)
+	})
+
+	content := []*yaml.Node{}
+	for _, pair := range pairs {
+		content = append(content, pair[0], pair[1])
+	}
+	node.Content = content
+}
+
+func indexOfYAMLKey(content []*yaml.Node, key string) int {
+	for i := 0; i+1 < len(content); i += 2 {
+		if content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func copyYAMLComments(dst *yaml.Node, src *yaml.Node) {
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+}
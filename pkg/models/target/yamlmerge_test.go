@@ -0,0 +1,75 @@
+package target
+
+import (
+	"testing"
+)
+
+func TestEncodeYAMLKeepingLayout(t *testing.T) {
+	old := `# zone vars
+timezone: UTC # keep UTC
+installDir: "/opt"
+removed: 1
+comp:
+    version: v1 # pinned
+    vars: {}
+`
+	v := map[string]interface{}{
+		"timezone":   "UTC",
+		"installDir": "/opt",
+		"logLevel":   "info",
+		"comp": map[string]interface{}{
+			"version": "v2",
+			"vars":    map[string]interface{}{"a": 1},
+		},
+	}
+
+	b, err := encodeYAMLKeepingLayout([]byte(old), v, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# zone vars
+timezone: UTC # keep UTC
+installDir: "/opt"
+logLevel: info
+comp:
+    version: v2 # pinned
+    vars:
+        a: 1
+`
+	if string(b) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(b))
+	}
+}
+
+func TestEncodeYAMLKeepingLayout_Order(t *testing.T) {
+	old := `timezone: UTC
+installDir: /opt
+comp:
+    version: v1
+`
+	v := map[string]interface{}{
+		"timezone":   "UTC",
+		"installDir": "/opt",
+		"zoneName":   "z1",
+		"comp":       map[string]interface{}{"version": "v1"},
+		"newComp":    map[string]interface{}{"version": "v1"},
+	}
+
+	b, err := encodeYAMLKeepingLayout([]byte(old), v, []string{"timezone", "zoneName", "installDir"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `timezone: UTC
+zoneName: z1
+installDir: /opt
+comp:
+    version: v1
+newComp:
+    version: v1
+`
+	if string(b) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(b))
+	}
+}
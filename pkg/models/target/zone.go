@@ -1,6 +1,7 @@
 package target
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/bougou/gopkg/common"
 	"github.com/bougou/sail/pkg/ansible"
+	"github.com/bougou/sail/pkg/diff"
 	"github.com/bougou/sail/pkg/models"
 	"github.com/bougou/sail/pkg/models/cmdb"
 	"github.com/bougou/sail/pkg/models/product"
@@ -21,6 +23,9 @@ const (
 	SailMetaVarProduct  = "_sail_product"
 	SailMetaVarHelmMode = "_sail_helm_mode"
 
+	SailMetaVarMigrationVersion = "_sail_migration_version"
+	SailMetaVarLabels           = "_sail_labels"
+
 	SailHelmModeComponent = "component"
 	SailHelmModeProduct   = "product"
 
@@ -33,6 +38,12 @@ type ZoneMeta struct {
 
 	// tag value must equal to SailMetaVarHelmMode
 	SailHelmMode string `json:"_sail_helm_mode" yaml:"_sail_helm_mode"`
+
+	// tag value must equal to SailMetaVarMigrationVersion
+	SailMigrationVersion int `json:"_sail_migration_version" yaml:"_sail_migration_version"`
+
+	// tag value must equal to SailMetaVarLabels
+	SailLabels Labels `json:"_sail_labels,omitempty" yaml:"_sail_labels,omitempty"`
 }
 
 type Zone struct {
@@ -46,17 +57,44 @@ type Zone struct {
 	HostsFile     string
 	PlatformsFile string
 	ComputedFile  string
+	SecretsFile   string
+	// InventoryFile is the hosts.yaml merged with the inventory sources, which is passed to ansible-playbook
+	// instead of hosts.yaml if the zone has inventory sources.
+	InventoryFile string
 
 	ResourcesDir string
 
 	HelmDir string
 
+	HistoryDir string
+	RunsDir    string
+	LogDir     string
+
 	Product    *product.Product
 	CMDB       *cmdb.CMDB
 	TargetVars *TargetVars
 
 	ansibleCfgFile string
 
+	// the decrypted secrets, only kept in memory
+	secrets map[string]string
+
+	// the effective versions (in the zone vars file) of the components whose versions are staged
+	stagedVersions map[string]string
+
+	// any zone file is encrypted by ansible-vault as a whole
+	vaultedSources bool
+
+	// a snapshot is taken before the zone files are overwritten, see snapshotBeforeWrite
+	snapshotted bool
+
+	// the inventory sources declared in hosts.yaml
+	inventorySources []*InventorySource
+	// the groups got from the inventory sources, and the same name groups in hosts.yaml overridden by them,
+	// nil if the inventory sources are not loaded
+	sourcesInventory *ansible.Inventory
+	overriddenGroups map[string]*ansible.Group
+
 	sailOption *models.SailOption
 }
 
@@ -72,11 +110,17 @@ func NewZone(sailOption *models.SailOption, targetName string, zoneName string)
 		HostsFile:     path.Join(sailOption.TargetsDir, targetName, zoneName, "hosts.yaml"),
 		PlatformsFile: path.Join(sailOption.TargetsDir, targetName, zoneName, "platforms.yaml"),
 		ComputedFile:  path.Join(sailOption.TargetsDir, targetName, zoneName, "_computed.yaml"),
+		SecretsFile:   path.Join(sailOption.TargetsDir, targetName, zoneName, "secrets.enc.yaml"),
+		InventoryFile: path.Join(sailOption.TargetsDir, targetName, zoneName, "_inventory.yaml"),
 
 		ResourcesDir: path.Join(sailOption.TargetsDir, targetName, zoneName, "resources"),
 
 		HelmDir: path.Join(sailOption.TargetsDir, targetName, zoneName, "helm"),
 
+		HistoryDir: path.Join(sailOption.TargetsDir, targetName, zoneName, ".history"),
+		RunsDir:    path.Join(sailOption.TargetsDir, targetName, zoneName, ".runs"),
+		LogDir:     path.Join(sailOption.TargetsDir, targetName, zoneName, "logs"),
+
 		CMDB:       cmdb.NewCMDB(),
 		TargetVars: NewTargetVars(),
 
@@ -85,6 +129,10 @@ func NewZone(sailOption *models.SailOption, targetName string, zoneName string)
 		sailOption: sailOption,
 	}
 
+	if sailOption.LogDir != "" {
+		zone.LogDir = path.Join(sailOption.LogDir, targetName, zoneName)
+	}
+
 	return zone
 }
 
@@ -106,6 +154,16 @@ func (zone *Zone) LoadNew() error {
 	// fill zone meta vars
 	zone.Product.Vars[SailMetaVarProduct] = zone.SailProduct
 	zone.Product.Vars[SailMetaVarHelmMode] = zone.SailHelmMode
+	if len(zone.SailLabels) != 0 {
+		zone.Product.Vars[SailMetaVarLabels] = map[string]string(zone.SailLabels)
+	}
+
+	// newly created zone already has the latest structure, no need to migrate
+	latestVersion, err := p.LatestMigrationVersion()
+	if err != nil {
+		return fmt.Errorf("load migrations failed, err: %s", err)
+	}
+	zone.Product.Vars[SailMetaVarMigrationVersion] = latestVersion
 
 	return nil
 }
@@ -113,6 +171,20 @@ func (zone *Zone) LoadNew() error {
 // Load initialize the zone. The zone is supposed to be already exists.
 // It will try to determine the product name from zone vars file.
 func (zone *Zone) Load() error {
+	if err := zone.LoadConf(); err != nil {
+		return err
+	}
+
+	if err := zone.PrepareHelm(); err != nil {
+		return fmt.Errorf("prepare helm failed, err: %s", err)
+	}
+
+	return nil
+}
+
+// LoadConf is like Load, but it only loads the configurations of the zone,
+// the helm charts of the zone are not prepared.
+func (zone *Zone) LoadConf() error {
 	zoneMeta, err := zone.ParseZoneMeta()
 	if err != nil {
 		return fmt.Errorf("parse zone meta failed, err: %s", err)
@@ -128,6 +200,14 @@ func (zone *Zone) Load() error {
 		return fmt.Errorf("init product failed, err: %s", err)
 	}
 
+	if latestVersion, err := p.LatestMigrationVersion(); err != nil {
+		return fmt.Errorf("load migrations failed, err: %s", err)
+	} else if zone.SailMigrationVersion < latestVersion {
+		// print to stderr, so the yaml or json output of the commands is not broken
+		fmt.Fprintf(os.Stderr, "warn: the zone has pending migrations (%d -> %d), run `sail conf-migrate -t %s -z %s` first\n",
+			zone.SailMigrationVersion, latestVersion, zone.TargetName, zone.ZoneName)
+	}
+
 	if err := zone.LoadHosts(); err != nil {
 		return fmt.Errorf("load hosts failed, err: %s", err)
 	}
@@ -136,16 +216,16 @@ func (zone *Zone) Load() error {
 		return fmt.Errorf("load platforms failed, err: %s", err)
 	}
 
-	if err := p.LoadZone(zone.VarsFile); err != nil {
+	b, err := zone.readZoneFile(zone.VarsFile)
+	if err != nil {
+		return fmt.Errorf("read zone vars file failed, err: %s", err)
+	}
+	if err := p.LoadZoneVars(b); err != nil {
 		return fmt.Errorf("load zone vars failed, err: %s", err)
 	}
 
 	zone.Product = p
 
-	if err := zone.PrepareHelm(); err != nil {
-		return fmt.Errorf("prepare helm failed, err: %s", err)
-	}
-
 	return nil
 }
 
@@ -165,20 +245,22 @@ func (zone *Zone) Compute() error {
 	return nil
 }
 
+// LoadTarget loads the vars of all zones of the target (targetvars).
+// The targetvars are loaded once per process and shared by the zones of the target,
+// so the zones run in parallel do not reload all zones each.
 func (zone *Zone) LoadTarget() error {
-	targetName := zone.TargetName
-	target := NewTarget(zone.sailOption, targetName)
-	if err := target.LoadAllZones(); err != nil {
-		return fmt.Errorf("load all zones for target (%s) failed, err: %s", targetName, err)
+	vars, err := loadTargetVars(zone.sailOption, zone.TargetName)
+	if err != nil {
+		return fmt.Errorf("load all zones for target (%s) failed, err: %s", zone.TargetName, err)
 	}
 
-	zone.TargetVars = target.vars
+	zone.TargetVars = vars
 
 	return nil
 }
 
 func (zone *Zone) ParseZoneMeta() (*ZoneMeta, error) {
-	b, err := os.ReadFile(zone.VarsFile)
+	b, err := zone.readZoneFile(zone.VarsFile)
 	if err != nil {
 		return nil, fmt.Errorf("read zone vars file failed, err: %s", err)
 	}
@@ -195,10 +277,58 @@ func (zone *Zone) ParseZoneMeta() (*ZoneMeta, error) {
 	return m, nil
 }
 
-func (zone *Zone) HandleCompatibity() {
-	// Todo
-	// Domain Specific Language (Declarative)
-	// migrate.yaml
+// HandleCompatibity applies the pending migrations declared in the migrate.yaml file of the product
+// to the zone vars file, and records the applied migration version into the zone vars file.
+// It returns the versions of the applied migrations.
+func (zone *Zone) HandleCompatibity() ([]int, error) {
+	zoneMeta, err := zone.ParseZoneMeta()
+	if err != nil {
+		return nil, fmt.Errorf("parse zone meta failed, err: %s", err)
+	}
+
+	p := product.NewProduct(zoneMeta.SailProduct, zone.sailOption.ProductsDir)
+	migrations, err := p.LoadMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("load migrations failed, err: %s", err)
+	}
+
+	b, err := zone.readZoneFile(zone.VarsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read zone vars file failed, err: %s", err)
+	}
+
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("yaml unmarshal failed, err: %s", err)
+	}
+	ansible.MarkVaulted(m)
+
+	applied := []int{}
+	for _, migration := range migrations {
+		if migration.Version <= zoneMeta.SailMigrationVersion {
+			continue
+		}
+		if err := migration.Apply(m); err != nil {
+			return nil, err
+		}
+		applied = append(applied, migration.Version)
+		m[SailMetaVarMigrationVersion] = migration.Version
+	}
+
+	if len(applied) == 0 {
+		return applied, nil
+	}
+
+	b, err = encodeYAMLKeepingLayout(b, m, p.VarsOrder())
+	if err != nil {
+		return nil, fmt.Errorf("encode vars failed, err: %s", err)
+	}
+
+	if err := zone.writeZoneFile(zone.VarsFile, b, false); err != nil {
+		return nil, fmt.Errorf("write vars file failed, err: %s", err)
+	}
+
+	return applied, nil
 }
 
 func (zone *Zone) Dump() error {
@@ -214,21 +344,47 @@ func (zone *Zone) Dump() error {
 		return fmt.Errorf("load target failed, err: %s", err)
 	}
 
-	errs := []string{}
-	if err := zone.RenderSailPlaybook(); err != nil {
-		errs = append(errs, err.Error())
-	}
-	if err := zone.RenderVars(); err != nil {
-		errs = append(errs, err.Error())
+	files, err := zone.renderFiles()
+	if err != nil {
+		return err
 	}
-	if err := zone.RenderHosts(); err != nil {
-		errs = append(errs, err.Error())
+
+	// snapshot the previous zone files before they are overwritten
+	for _, f := range files {
+		if err := zone.snapshotBeforeWrite(f.name, f.old, f.content); err != nil {
+			return err
+		}
 	}
-	if err := zone.RenderPlatforms(); err != nil {
-		errs = append(errs, err.Error())
+
+	errs := []string{}
+	for _, f := range files {
+		content := f.content
+		if f.vaulted {
+			// keep the vaulted file untouched if not changed, the encrypted content differs every time
+			if !f.changed() && f.oldVaulted {
+				continue
+			}
+			b, err := zone.runAnsibleVault("encrypt", content)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("encrypt file (%s) failed, err: %s", f.name, err))
+				continue
+			}
+			content = b
+		}
+
+		// the product sail playbook is shared by the zones which may be dumped concurrently,
+		// write atomically so ansible-playbook never reads a partially written file
+		if err := writeFileAtomic(f.name, content, 0644); err != nil {
+			errs = append(errs, fmt.Sprintf("write file (%s) failed, err: %s", f.name, err))
+		}
 	}
-	if err := zone.RenderComputed(); err != nil {
-		errs = append(errs, err.Error())
+
+	// remove the stale merged inventory file, eg: the inventory sources are frozen.
+	// it is kept if the inventory sources are declared but not loaded, eg: by conf-update
+	if !zone.HasInventorySources() {
+		if err := os.Remove(zone.InventoryFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Sprintf("remove file (%s) failed, err: %s", zone.InventoryFile, err))
+		}
 	}
 
 	if len(errs) != 0 {
@@ -237,27 +393,170 @@ func (zone *Zone) Dump() error {
 	return nil
 }
 
+// Plan is like Dump, but it renders all files of the zone in memory,
+// and returns the unified diff against the files on disk, the secrets in the diff are masked. Nothing is written.
+func (zone *Zone) Plan() (string, error) {
+	if err := zone.Compute(); err != nil {
+		return "", fmt.Errorf("zone compute failed, err: %s", err)
+	}
+
+	if err := zone.LoadTarget(); err != nil {
+		return "", fmt.Errorf("load target failed, err: %s", err)
+	}
+
+	files, err := zone.renderFiles()
+	if err != nil {
+		return "", err
+	}
+
+	sb := &strings.Builder{}
+	for _, f := range files {
+		// do not print the decrypted content of the vaulted file
+		if f.vaulted {
+			if f.changed() {
+				sb.WriteString(fmt.Sprintf("file (%s) encrypted by ansible-vault changed, the diff is not shown\n", f.name))
+			}
+			continue
+		}
+		sb.WriteString(diff.Unified(f.name, f.name, string(f.old), string(f.content)))
+	}
+
+	return zone.MaskSecretsString(sb.String()), nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same dir, then renames it to name.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(path.Dir(name), "."+path.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+// renderedFile holds the rendered content and the content on disk of a zone file.
+type renderedFile struct {
+	name    string
+	content []byte
+	old     []byte
+
+	// the file is written encrypted by ansible-vault as a whole
+	vaulted bool
+	// the file on disk is encrypted by ansible-vault as a whole
+	oldVaulted bool
+}
+
+func (f *renderedFile) changed() bool {
+	return !bytes.Equal(f.content, f.old)
+}
+
+// renderFiles renders all files dumped by the zone in memory.
+func (zone *Zone) renderFiles() ([]*renderedFile, error) {
+	type render struct {
+		name   string
+		encode func() ([]byte, error)
+		// the file holds the values copied from the other zone files
+		derived bool
+	}
+
+	renders := []render{
+		{zone.Product.SailPlaybookFile(), zone.encodeSailPlaybook, false},
+		{zone.VarsFile, zone.encodeVars, false},
+		{zone.HostsFile, zone.encodeHosts, false},
+		{zone.PlatformsFile, zone.encodePlatforms, false},
+		{zone.ComputedFile, zone.encodeComputed, true},
+	}
+	if zone.sourcesInventory != nil {
+		renders = append(renders, render{zone.InventoryFile, zone.encodeInventory, true})
+	}
+
+	files := []*renderedFile{}
+	for _, r := range renders {
+		b, err := r.encode()
+		if err != nil {
+			return nil, err
+		}
+
+		old, err := os.ReadFile(r.name)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("read file (%s) failed, err: %s", r.name, err)
+		}
+
+		// compare with the decrypted content for the file encrypted by ansible-vault as a whole
+		oldVaulted := ansible.IsVaulted(old)
+		if oldVaulted {
+			old, err = zone.runAnsibleVault("decrypt", old)
+			if err != nil {
+				return nil, fmt.Errorf("decrypt file (%s) failed, err: %s", r.name, err)
+			}
+		}
+
+		// the derived files must not leak the decrypted values of the vaulted files in plain text
+		vaulted := oldVaulted || (r.derived && zone.encryptDerived())
+
+		files = append(files, &renderedFile{name: r.name, content: b, old: old, vaulted: vaulted, oldVaulted: oldVaulted})
+	}
+
+	return files, nil
+}
+
 // RenderSailPlaybook renders the default temporary ansible playbook file for the product of the zone.
 func (zone *Zone) RenderSailPlaybook() error {
-	product := zone.Product
-	playbook, err := product.GenSail()
+	b, err := zone.encodeSailPlaybook()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(zone.Product.SailPlaybookFile(), b, 0644); err != nil {
+		return fmt.Errorf("write product sail playbook file failed, err: %s", err)
+	}
+
+	return nil
+}
+
+func (zone *Zone) encodeSailPlaybook() ([]byte, error) {
+	playbook, err := zone.Product.GenSail()
 	if err != nil {
-		return fmt.Errorf("gen sail playbook failed, err: %s", err)
+		return nil, fmt.Errorf("gen sail playbook failed, err: %s", err)
 	}
 
 	b, err := common.Encode("yaml", playbook)
 	if err != nil {
-		fmt.Println("encode vars failed", err)
+		return nil, fmt.Errorf("encode sail playbook failed, err: %s", err)
 	}
 
-	if err := os.WriteFile(zone.Product.SailPlaybookFile(), b, 0644); err != nil {
-		fmt.Println("write product sail playbook file failed", err)
+	return b, nil
+}
+
+func (zone *Zone) RenderVars() error {
+	b, err := zone.encodeVars()
+	if err != nil {
+		return err
+	}
+
+	if err := zone.writeZoneFile(zone.VarsFile, b, false); err != nil {
+		return fmt.Errorf("write vars file failed, err: %s", err)
 	}
 
 	return nil
 }
 
-func (zone *Zone) RenderVars() error {
+func (zone *Zone) encodeVars() ([]byte, error) {
 	m := make(map[string]interface{})
 
 	for k, v := range zone.Product.Vars {
@@ -268,45 +567,95 @@ func (zone *Zone) RenderVars() error {
 		m[k] = v
 	}
 
-	b, err := common.Encode("yaml", m)
-	if err != nil {
-		return fmt.Errorf("encode vars failed, err: %s", err)
+	// the staged versions are not written until committed
+	for k, version := range zone.stagedVersions {
+		c := *zone.Product.Components[k]
+		c.Version = version
+		m[k] = &c
 	}
 
-	if err := os.WriteFile(zone.VarsFile, b, 0644); err != nil {
-		return fmt.Errorf("write vars file failed, err: %s", err)
+	// keep the comments and the key order of the existing vars file
+	old, err := zone.readZoneFile(zone.VarsFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read zone vars file failed, err: %s", err)
 	}
 
-	return nil
+	b, err := encodeYAMLKeepingLayout(old, m, zone.Product.VarsOrder())
+	if err != nil {
+		return nil, fmt.Errorf("encode vars failed, err: %s", err)
+	}
+
+	return b, nil
 }
 
 func (zone *Zone) RenderHosts() error {
-	b, err := common.Encode("yaml", zone.CMDB.Inventory)
+	b, err := zone.encodeHosts()
 	if err != nil {
-		return fmt.Errorf("encode cmdb inventory failed, err: %s", err)
+		return err
 	}
 
-	if err := os.WriteFile(zone.HostsFile, b, 0644); err != nil {
+	if err := zone.writeZoneFile(zone.HostsFile, b, false); err != nil {
 		return fmt.Errorf("write hosts file failed, err: %s", err)
 	}
 
 	return nil
 }
 
+func (zone *Zone) encodeHosts() ([]byte, error) {
+	b, err := common.Encode("yaml", zone.staticInventory())
+	if err != nil {
+		return nil, fmt.Errorf("encode cmdb inventory failed, err: %s", err)
+	}
+
+	return b, nil
+}
+
+// encodeInventory encodes the inventory merged with the inventory sources.
+func (zone *Zone) encodeInventory() ([]byte, error) {
+	b, err := common.Encode("yaml", zone.CMDB.Inventory)
+	if err != nil {
+		return nil, fmt.Errorf("encode cmdb inventory failed, err: %s", err)
+	}
+
+	return b, nil
+}
+
 func (zone *Zone) RenderPlatforms() error {
-	b, err := common.Encode("yaml", zone.CMDB.Platforms)
+	b, err := zone.encodePlatforms()
 	if err != nil {
-		return fmt.Errorf("encode cmdb platforms failed, err: %s", err)
+		return err
 	}
 
-	if err := os.WriteFile(zone.PlatformsFile, b, 0644); err != nil {
+	if err := zone.writeZoneFile(zone.PlatformsFile, b, false); err != nil {
 		return fmt.Errorf("write platforms file failed, err: %s", err)
 	}
 
 	return nil
 }
 
+func (zone *Zone) encodePlatforms() ([]byte, error) {
+	b, err := common.Encode("yaml", zone.CMDB.Platforms)
+	if err != nil {
+		return nil, fmt.Errorf("encode cmdb platforms failed, err: %s", err)
+	}
+
+	return b, nil
+}
+
 func (zone *Zone) RenderComputed() error {
+	b, err := zone.encodeComputed()
+	if err != nil {
+		return err
+	}
+
+	if err := zone.writeZoneFile(zone.ComputedFile, b, zone.encryptDerived()); err != nil {
+		return fmt.Errorf("write computed file failed, err: %s", err)
+	}
+
+	return nil
+}
+
+func (zone *Zone) encodeComputed() ([]byte, error) {
 	m := make(map[string]interface{})
 	m["inventory"] = zone.CMDB.Inventory
 	m["platforms"] = zone.CMDB.Platforms
@@ -314,14 +663,10 @@ func (zone *Zone) RenderComputed() error {
 
 	b, err := common.Encode("yaml", m)
 	if err != nil {
-		return fmt.Errorf("encode vars failed, err: %s", err)
+		return nil, fmt.Errorf("encode vars failed, err: %s", err)
 	}
 
-	if err := os.WriteFile(zone.ComputedFile, b, 0644); err != nil {
-		return fmt.Errorf("write computed file failed, err: %s", err)
-	}
-
-	return nil
+	return b, nil
 }
 
 func (zone *Zone) PatchActionHostsMap(m map[string][]ansible.ActionHosts) error {
@@ -343,7 +688,7 @@ func (zone *Zone) PatchActionHosts(groupName string, hostsPatch *ansible.ActionH
 		group, _ := zone.CMDB.Inventory.GetGroup(groupName)
 		ansible.PatchAnsibleGroup(group, hostsPatch)
 	} else {
-		if hostsPatch.Action == "delete" {
+		if hostsPatch.Action == "remove" {
 			return
 		}
 
@@ -394,16 +739,30 @@ func (zone *Zone) PlaybookFile(playbookName string) string {
 	return path.Join(zone.Product.Dir, product.DefaultPlaybookFile)
 }
 
+// SetComponentVersion sets the version of the component to deploy.
+// The version is staged, it is not written to the zone vars file until committed by CommitComponentVersion,
+// which is done by RunningZone after the component is successfully deployed.
 func (zone *Zone) SetComponentVersion(componentName string, componentVersion string) error {
 	if !zone.Product.HasComponent(componentName) {
 		return fmt.Errorf("zone does not have component: (%s)", componentName)
 	}
-	zone.Product.Components[componentName].Version = componentVersion
+
+	c := zone.Product.Components[componentName]
+	if c.Version == componentVersion {
+		return nil
+	}
+	if _, staged := zone.stagedVersions[componentName]; !staged {
+		if zone.stagedVersions == nil {
+			zone.stagedVersions = make(map[string]string)
+		}
+		zone.stagedVersions[componentName] = c.Version
+	}
+	c.Version = componentVersion
 	return nil
 }
 
 func (zone *Zone) LoadHosts() error {
-	b, err := os.ReadFile(zone.HostsFile)
+	b, err := zone.readZoneFile(zone.HostsFile)
 	if err != nil {
 		return fmt.Errorf("read file (%s) failed, err: %s", zone.HostsFile, err)
 	}
@@ -412,15 +771,24 @@ func (zone *Zone) LoadHosts() error {
 	if err := yaml.Unmarshal(b, i); err != nil {
 		return fmt.Errorf("unmarshal hosts failed, err: %s", err)
 	}
+	i.MarkVaulted()
 
 	zone.CMDB.Inventory = i
+
+	// the sources are loaded on demand by LoadInventorySources
+	sources, err := ParseInventorySources(i)
+	if err != nil {
+		return err
+	}
+	zone.inventorySources = sources
+
 	return nil
 }
 
 func (zone *Zone) LoadPlatforms() error {
 	i := map[string]cmdb.Platform{}
 
-	b, err := os.ReadFile(zone.PlatformsFile)
+	b, err := zone.readZoneFile(zone.PlatformsFile)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			zone.CMDB.Platforms = i
@@ -0,0 +1,177 @@
+package target
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bougou/sail/pkg/models"
+)
+
+const testComponentsYAML = `foobar-api:
+  version: v1.0.0
+  enabled: true
+  form: server
+  services:
+    default:
+      scheme: http
+      port: 8080
+  vars:
+    log_level: info
+foobar-db:
+  version: v2.0.0
+  enabled: false
+  external: true
+  form: server
+  services:
+    default:
+      scheme: tcp
+      host: db.example.com
+      port: 3306
+`
+
+// newTestSailOption creates a products dir with the "foobar" product, and an empty targets dir.
+func newTestSailOption(t *testing.T) *models.SailOption {
+	dir := t.TempDir()
+	sailOption := &models.SailOption{
+		ProductsDir: path.Join(dir, "products"),
+		PackagesDir: path.Join(dir, "packages"),
+		TargetsDir:  path.Join(dir, "targets"),
+	}
+
+	writeTestFiles(t, path.Join(sailOption.ProductsDir, "foobar"), map[string]string{
+		"vars.yaml":       "installDir: /opt\ndataDir: /data\n",
+		"components.yaml": testComponentsYAML,
+	})
+	if err := os.MkdirAll(path.Join(sailOption.ProductsDir, "foobar", "components"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	return sailOption
+}
+
+// newTestZone creates the zone with the vars file and the hosts file, and loads it.
+func newTestZone(t *testing.T, sailOption *models.SailOption, targetName string, zoneName string, vars string, hosts string) *Zone {
+	zone := NewZone(sailOption, targetName, zoneName)
+	writeTestFiles(t, zone.ZoneDir, map[string]string{
+		"vars.yaml":  "_sail_product: foobar\n" + vars,
+		"hosts.yaml": hosts,
+	})
+
+	if err := zone.LoadConf(); err != nil {
+		t.Fatalf("load zone failed, err: %s", err)
+	}
+	return zone
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		f := path.Join(dir, name)
+		if err := os.MkdirAll(path.Dir(f), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// forgetTargetVars drops the cached targetvars, as if a new sail command is run.
+func forgetTargetVars() {
+	targetVarsCache.Lock()
+	defer targetVarsCache.Unlock()
+	targetVarsCache.m = make(map[string]*cachedTargetVars)
+}
+
+// fakeAnsibleVault puts a fake ansible-vault command into PATH, which "encrypts" by base64.
+func fakeAnsibleVault(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+action=$1
+if [ "$action" = encrypt ]; then
+  printf '$ANSIBLE_VAULT;1.1;AES256\n'; base64
+else
+  tail -n +2 | base64 -d
+fi
+`
+	writeTestFiles(t, dir, map[string]string{"ansible-vault": script})
+	if err := os.Chmod(path.Join(dir, "ansible-vault"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(filepath.ListSeparator)+oldPath)
+	t.Cleanup(func() { os.Setenv("PATH", oldPath) })
+}
+
+func TestZone_DumpEncryptsDerivedFiles(t *testing.T) {
+	fakeAnsibleVault(t)
+
+	sailOption := newTestSailOption(t)
+	sailOption.VaultPasswordFile = "/dev/null"
+	hosts := "foobar-api:\n  hosts:\n    10.0.0.1: {}\n"
+
+	// z1 is encrypted as a whole, z2 is plain
+	z1 := newTestZone(t, sailOption, "t1", "z1", "db_password: plain-s3cr3t\n", hosts)
+	b, err := z1.runAnsibleVault("encrypt", []byte("_sail_product: foobar\ndb_password: plain-s3cr3t\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, z1.ZoneDir, map[string]string{"vars.yaml": string(b)})
+	z2 := newTestZone(t, sailOption, "t1", "z2", "", hosts)
+
+	for _, zone := range []*Zone{NewZone(sailOption, "t1", "z1"), z2} {
+		if err := zone.LoadConf(); err != nil {
+			t.Fatal(err)
+		}
+		if err := zone.Dump(); err != nil {
+			t.Fatalf("dump zone (%s) failed, err: %s", zone.ZoneName, err)
+		}
+
+		b, err := os.ReadFile(zone.ComputedFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(b), "plain-s3cr3t") {
+			t.Errorf("found the plain text secret in %s", zone.ComputedFile)
+		}
+
+		plain, err := zone.readZoneFile(zone.ComputedFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(plain), "plain-s3cr3t") {
+			t.Errorf("not found the secret in the decrypted %s", zone.ComputedFile)
+		}
+	}
+}
+
+func TestZone_LoadTarget(t *testing.T) {
+	sailOption := newTestSailOption(t)
+	z1 := newTestZone(t, sailOption, "t1", "z1", "", "")
+	z2 := newTestZone(t, sailOption, "t1", "z2", "", "")
+
+	// the targetvars are loaded once and shared by the zones
+	for _, zone := range []*Zone{z1, z2} {
+		if err := zone.LoadTarget(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if z1.TargetVars != z2.TargetVars {
+		t.Error("expected the targetvars shared by the zones")
+	}
+	if len(z1.TargetVars.Zones) != 2 {
+		t.Errorf("expected the vars of 2 zones, got %d", len(z1.TargetVars.Zones))
+	}
+
+	newTestZone(t, sailOption, "t1", "z3", "", "")
+	forgetTargetVars()
+	if err := z1.LoadTarget(); err != nil {
+		t.Fatal(err)
+	}
+	if len(z1.TargetVars.Zones) != 3 {
+		t.Errorf("expected the vars of 3 zones after reloaded, got %d", len(z1.TargetVars.Zones))
+	}
+}
@@ -0,0 +1,53 @@
+package options
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bougou/gopkg/common"
+)
+
+const (
+	OutputTable = "table"
+	OutputYAML  = "yaml"
+	OutputJSON  = "json"
+)
+
+// ValidateOutput returns error if the value of --output option is not supported.
+func ValidateOutput(output string) error {
+	switch output {
+	case OutputTable, OutputYAML, OutputJSON:
+		return nil
+	default:
+		return fmt.Errorf("not supported --output value (%s), valid values: table, yaml, json", output)
+	}
+}
+
+// PrintEncoded prints v encoded in yaml or json format.
+func PrintEncoded(output string, v interface{}) error {
+	return FprintEncoded(os.Stdout, output, v)
+}
+
+// FprintEncoded is like PrintEncoded, but prints to w.
+func FprintEncoded(w io.Writer, output string, v interface{}) error {
+	b, err := common.Encode(output, v)
+	if err != nil {
+		return fmt.Errorf("encode %s failed, err: %s", output, err)
+	}
+
+	fmt.Fprint(w, string(b))
+	if len(b) != 0 && b[len(b)-1] != '\n' {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// FormatTime formats the time for table output, the zero time is formatted as "-".
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -2,13 +2,20 @@ package options
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/fatih/color"
 )
 
 func PrintColorHeader(targetName string, zoneName string) {
+	FprintColorHeader(os.Stdout, targetName, zoneName)
+}
+
+// FprintColorHeader is like PrintColorHeader, but writes to w, eg: the output of the zone run in parallel.
+func FprintColorHeader(w io.Writer, targetName string, zoneName string) {
 	// d.Printf("👉 target: (%s), zone: (%s)\n", o.TargetName, o.ZoneName)
 	d := color.New(color.FgGreen)
 	s := fmt.Sprintf("👉 target: (%s), zone: (%s)", d.Sprint(targetName), d.Sprint(zoneName))
-	fmt.Println(s)
+	fmt.Fprintln(w, s)
 }
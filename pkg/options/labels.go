@@ -0,0 +1,45 @@
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bougou/sail/pkg/models/target"
+)
+
+// ParseLabelsOption parses --label options into the labels to set and the label keys to remove.
+//
+// eg options:
+//
+//	--label region=east               # set label region to east
+//	--label tier=canary,owner=ops     # specify multiple labels with comma separated
+//	--label owner-                    # remove label owner
+func ParseLabelsOption(labelsOptions []string) (target.Labels, []string, error) {
+	labels := target.Labels{}
+	removed := []string{}
+
+	for _, labelsOption := range labelsOptions {
+		for _, labelOpt := range strings.Split(labelsOption, ",") {
+			labelOpt = strings.TrimSpace(labelOpt)
+			if labelOpt == "" {
+				continue
+			}
+
+			if strings.HasSuffix(labelOpt, "-") && !strings.Contains(labelOpt, "=") {
+				removed = append(removed, strings.TrimSuffix(labelOpt, "-"))
+				continue
+			}
+
+			kv := strings.SplitN(labelOpt, "=", 2)
+			if len(kv) != 2 {
+				return nil, nil, fmt.Errorf("wrong --label option value (%s), must be key=value or key-", labelOpt)
+			}
+			if err := target.ValidateLabel(kv[0], kv[1]); err != nil {
+				return nil, nil, err
+			}
+			labels[kv[0]] = kv[1]
+		}
+	}
+
+	return labels, removed, nil
+}
@@ -0,0 +1,101 @@
+package options
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// outputMu serializes the lines written to the terminal by the zones run in parallel.
+var outputMu sync.Mutex
+
+// ZoneIO holds the standard streams for running a zone.
+type ZoneIO struct {
+	// In is nil when the zone is not run in the foreground
+	In     io.Reader
+	Out    io.Writer
+	ErrOut io.Writer
+}
+
+// StdZoneIO returns the ZoneIO of the standard streams of the process.
+func StdZoneIO() *ZoneIO {
+	return &ZoneIO{
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+	}
+}
+
+// prefixedZoneIO returns the ZoneIO whose output lines are prefixed with the zone name, and has no input.
+func prefixedZoneIO(zoneName string) *ZoneIO {
+	prefix := "[" + zoneName + "] "
+	return &ZoneIO{
+		In:     nil,
+		Out:    NewPrefixWriter(os.Stdout, prefix),
+		ErrOut: NewPrefixWriter(os.Stderr, prefix),
+	}
+}
+
+// Flush writes the buffered incomplete lines of the output.
+func (zoneIO *ZoneIO) Flush() {
+	for _, w := range []io.Writer{zoneIO.Out, zoneIO.ErrOut} {
+		if pw, ok := w.(*PrefixWriter); ok {
+			pw.Flush()
+		}
+	}
+}
+
+// PrefixWriter adds the prefix to each line written to the underlying writer.
+// Each line is written as a whole, so the lines from multiple writers are not mixed up.
+type PrefixWriter struct {
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+	mu     sync.Mutex
+}
+
+func NewPrefixWriter(w io.Writer, prefix string) *PrefixWriter {
+	return &PrefixWriter{
+		w:      w,
+		prefix: []byte(prefix),
+	}
+}
+
+func (pw *PrefixWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.buf = append(pw.buf, p...)
+	for {
+		i := bytes.IndexByte(pw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := pw.writeLine(pw.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		pw.buf = pw.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the buffered incomplete line.
+func (pw *PrefixWriter) Flush() {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if len(pw.buf) != 0 {
+		_ = pw.writeLine(append(pw.buf, '\n'))
+		pw.buf = nil
+	}
+}
+
+func (pw *PrefixWriter) writeLine(line []byte) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	_, err := pw.w.Write(append(append([]byte{}, pw.prefix...), line...))
+	return err
+}
@@ -0,0 +1,43 @@
+package options
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/fatih/color"
+)
+
+// PrintZonePlan prints the unified diff of the files which would be rewritten by dumping the zone to w.
+func PrintZonePlan(w io.Writer, zone *target.Zone) error {
+	d, err := zone.Plan()
+	if err != nil {
+		return fmt.Errorf("zone.Plan failed, err: %s", err)
+	}
+
+	if d == "" {
+		fmt.Fprintln(w, "no changes to zone files")
+		return nil
+	}
+
+	added := color.New(color.FgGreen)
+	removed := color.New(color.FgRed)
+	hunk := color.New(color.FgCyan)
+	for _, line := range strings.SplitAfter(d, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Fprint(w, line)
+		case strings.HasPrefix(line, "+"):
+			added.Fprint(w, line)
+		case strings.HasPrefix(line, "-"):
+			removed.Fprint(w, line)
+		case strings.HasPrefix(line, "@@"):
+			hunk.Fprint(w, line)
+		default:
+			fmt.Fprint(w, line)
+		}
+	}
+
+	return nil
+}
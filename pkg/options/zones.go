@@ -0,0 +1,328 @@
+package options
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bougou/sail/pkg/models"
+	"github.com/bougou/sail/pkg/models/target"
+	"github.com/fatih/color"
+)
+
+const (
+	OnErrorContinue = "continue"
+	OnErrorStop     = "stop"
+)
+
+// ValidateOnError returns error if the value of --on-error option is not supported.
+func ValidateOnError(onError string) error {
+	switch onError {
+	case OnErrorContinue, OnErrorStop:
+		return nil
+	default:
+		return fmt.Errorf("not supported --on-error value (%s), valid values: continue, stop", onError)
+	}
+}
+
+// ChooseZones returns the names of the zones of the target matching any of the patterns and the label selector.
+// The patterns are shell globs (eg: 'prod-*'), and multiple patterns can be separated by comma.
+// The selector selects zones by their labels (eg: 'tier=canary,region!=east'), see target.ParseSelector.
+// All zones are returned if no patterns and no selector specified.
+func ChooseZones(sailOption *models.SailOption, targetName string, patterns []string, selector string) ([]string, error) {
+	t := target.NewTarget(sailOption, targetName)
+	zoneNames, err := t.AllZones()
+	if err != nil {
+		return nil, fmt.Errorf("determine all zones for target (%s) failed, err: %s", targetName, err)
+	}
+
+	sel, err := target.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	globs := []string{}
+	for _, pattern := range patterns {
+		for _, glob := range strings.Split(pattern, ",") {
+			glob = strings.TrimSpace(glob)
+			if glob == "" {
+				continue
+			}
+			if err := target.ValidateZoneGlob(glob); err != nil {
+				return nil, err
+			}
+			globs = append(globs, glob)
+		}
+	}
+	if len(globs) == 0 && sel.Empty() {
+		return zoneNames, nil
+	}
+
+	out := []string{}
+	for _, zoneName := range zoneNames {
+		if len(globs) != 0 && !target.MatchZoneGlobs(globs, zoneName) {
+			continue
+		}
+
+		if !sel.Empty() {
+			zoneMeta, err := target.NewZone(sailOption, targetName, zoneName).ParseZoneMeta()
+			if err != nil {
+				return nil, fmt.Errorf("parse zone meta for zone (%s) failed, err: %s", zoneName, err)
+			}
+			if !sel.Matches(zoneMeta.SailLabels) {
+				continue
+			}
+		}
+
+		out = append(out, zoneName)
+	}
+
+	if len(out) == 0 {
+		conditions := []string{}
+		if len(globs) != 0 {
+			conditions = append(conditions, fmt.Sprintf("zones (%s)", strings.Join(globs, ",")))
+		}
+		if !sel.Empty() {
+			conditions = append(conditions, fmt.Sprintf("selector (%s)", selector))
+		}
+		return nil, fmt.Errorf("no zones of target (%s) match %s", targetName, strings.Join(conditions, " and "))
+	}
+	return out, nil
+}
+
+// ZoneResult is the result of running a command for a zone.
+type ZoneResult struct {
+	ZoneName string
+	Err      error
+	// the zone is not run because a previous zone failed and --on-error is stop
+	Skipped  bool
+	Duration time.Duration
+}
+
+// ValidateParallel returns error if the value of --parallel option is not valid.
+func ValidateParallel(parallel int) error {
+	if parallel < 1 {
+		return fmt.Errorf("invalid --parallel value (%d), must be at least 1", parallel)
+	}
+	return nil
+}
+
+// RunZones calls fn for each zone, at most `parallel` zones are run at the same time.
+// When a zone fails, the remaining zones are skipped if onError is stop, or continued if onError is continue.
+//
+// When run in parallel, the output of each zone is prefixed with the zone name,
+// the zones get no input, and the progress is printed when a zone starts or finishes.
+func RunZones(zoneNames []string, onError string, parallel int, fn func(zoneName string, zoneIO *ZoneIO) error) []*ZoneResult {
+	if parallel <= 1 {
+		return runZonesSequentially(zoneNames, onError, fn)
+	}
+
+	results := make([]*ZoneResult, len(zoneNames))
+	p := newProgress(len(zoneNames))
+	defer p.stop()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stopped bool
+	)
+	sem := make(chan struct{}, parallel)
+
+	for i, zoneName := range zoneNames {
+		sem <- struct{}{}
+
+		mu.Lock()
+		skip := stopped
+		mu.Unlock()
+		if skip {
+			<-sem
+			results[i] = &ZoneResult{ZoneName: zoneName, Skipped: true}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, zoneName string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			p.start(zoneName)
+			zoneIO := prefixedZoneIO(zoneName)
+
+			start := time.Now()
+			err := fn(zoneName, zoneIO)
+			zoneIO.Flush()
+			results[i] = &ZoneResult{ZoneName: zoneName, Err: err, Duration: time.Since(start)}
+
+			if err != nil {
+				printLine(color.New(color.FgRed).Sprintf("❌ zone (%s) failed, err: %s", zoneName, err))
+				if onError == OnErrorStop {
+					mu.Lock()
+					stopped = true
+					mu.Unlock()
+				}
+			}
+			p.finish(zoneName, err)
+		}(i, zoneName)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func runZonesSequentially(zoneNames []string, onError string, fn func(zoneName string, zoneIO *ZoneIO) error) []*ZoneResult {
+	results := []*ZoneResult{}
+
+	stopped := false
+	for i, zoneName := range zoneNames {
+		if stopped {
+			results = append(results, &ZoneResult{ZoneName: zoneName, Skipped: true})
+			continue
+		}
+
+		if i != 0 {
+			fmt.Printf("\n\n\n")
+		}
+
+		start := time.Now()
+		err := fn(zoneName, StdZoneIO())
+		results = append(results, &ZoneResult{ZoneName: zoneName, Err: err, Duration: time.Since(start)})
+
+		if err != nil {
+			color.New(color.FgRed).Printf("❌ zone (%s) failed, err: %s\n", zoneName, err)
+			if onError == OnErrorStop {
+				stopped = true
+			}
+		}
+	}
+
+	return results
+}
+
+// progressInterval is the interval to print the progress when no zone starts or finishes.
+const progressInterval = 30 * time.Second
+
+// progress tracks and prints the progress of the zones run in parallel.
+type progress struct {
+	total    int
+	finished int
+	failed   int
+	running  map[string]bool
+
+	mu     sync.Mutex
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+func newProgress(total int) *progress {
+	p := &progress{
+		total:   total,
+		running: make(map[string]bool),
+		ticker:  time.NewTicker(progressInterval),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		for {
+			select {
+			case <-p.ticker.C:
+				p.print()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+func (p *progress) start(zoneName string) {
+	p.mu.Lock()
+	p.running[zoneName] = true
+	p.mu.Unlock()
+	p.print()
+}
+
+func (p *progress) finish(zoneName string, err error) {
+	p.mu.Lock()
+	delete(p.running, zoneName)
+	p.finished++
+	if err != nil {
+		p.failed++
+	}
+	p.mu.Unlock()
+	p.print()
+}
+
+func (p *progress) stop() {
+	p.ticker.Stop()
+	close(p.done)
+}
+
+func (p *progress) print() {
+	p.mu.Lock()
+	running := []string{}
+	for zoneName := range p.running {
+		running = append(running, zoneName)
+	}
+	sort.Strings(running)
+	s := fmt.Sprintf("⏳ progress: (%d/%d) zones finished, (%d) failed, running: %s", p.finished, p.total, p.failed, strings.Join(running, ","))
+	p.mu.Unlock()
+
+	printLine(color.New(color.FgCyan).Sprint(s))
+}
+
+// printLine prints the line to stdout without being mixed up with the output of the zones.
+func printLine(s string) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Println(s)
+}
+
+// PrintZoneResults prints the summary table of the zone results,
+// and returns error if any zone failed or skipped.
+// Only the first line of the multi-line errors is shown in the table, the full errors are printed below it.
+func PrintZoneResults(targetName string, results []*ZoneResult) error {
+	return FprintZoneResults(os.Stdout, targetName, results)
+}
+
+// FprintZoneResults is like PrintZoneResults, but prints to out.
+func FprintZoneResults(out io.Writer, targetName string, results []*ZoneResult) error {
+	failed, skipped := 0, 0
+	multiLineErrs := []*ZoneResult{}
+
+	fmt.Fprintf(out, "\n\nsummary of target (%s):\n", targetName)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ZONE\tRESULT\tDURATION\tERROR")
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			skipped++
+			fmt.Fprintf(w, "%s\t%s\t-\t\n", r.ZoneName, "skipped")
+		case r.Err != nil:
+			failed++
+			errMsg := r.Err.Error()
+			if i := strings.Index(errMsg, "\n"); i >= 0 {
+				errMsg = errMsg[:i] + " ..."
+				multiLineErrs = append(multiLineErrs, r)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ZoneName, "failed", r.Duration.Round(time.Second), errMsg)
+		default:
+			fmt.Fprintf(w, "%s\t%s\t%s\t\n", r.ZoneName, "succeeded", r.Duration.Round(time.Second))
+		}
+	}
+	w.Flush()
+
+	for _, r := range multiLineErrs {
+		fmt.Fprintf(out, "\nerror of zone (%s):\n%s\n", r.ZoneName, r.Err)
+	}
+
+	if failed != 0 || skipped != 0 {
+		return fmt.Errorf("(%d) of (%d) zones failed, (%d) zones skipped", failed, len(results), skipped)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package options
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunZones(t *testing.T) {
+	zoneNames := []string{"z1", "z2", "z3", "z4"}
+
+	var running, maxRunning int32
+	fn := func(zoneName string, zoneIO *ZoneIO) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+
+		fmt.Fprintf(zoneIO.Out, "deploying %s", zoneName)
+		if zoneName == "z2" {
+			return errors.New("failed")
+		}
+		return nil
+	}
+
+	results := RunZones(zoneNames, OnErrorContinue, 2, fn)
+	if len(results) != len(zoneNames) {
+		t.Fatalf("expected %d results, got %d", len(zoneNames), len(results))
+	}
+	for i, r := range results {
+		if r.ZoneName != zoneNames[i] {
+			t.Errorf("expected result of zone %s at %d, got %s", zoneNames[i], i, r.ZoneName)
+		}
+		if (r.Err != nil) != (r.ZoneName == "z2") || r.Skipped {
+			t.Errorf("unexpected result for zone %s: %+v", r.ZoneName, r)
+		}
+	}
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 zones running at the same time, got %d", maxRunning)
+	}
+	if err := PrintZoneResults("t", results); err == nil {
+		t.Errorf("expected error when a zone failed")
+	}
+
+	results = RunZones(zoneNames, OnErrorStop, 1, fn)
+	if !results[2].Skipped || !results[3].Skipped {
+		t.Errorf("expected the zones after the failed zone skipped, got %+v %+v", results[2], results[3])
+	}
+}
+
+func TestFprintZoneResults(t *testing.T) {
+	results := []*ZoneResult{
+		{ZoneName: "z1"},
+		{ZoneName: "z2", Err: errors.New("apply failed\nTASK [foo] fatal")},
+		{ZoneName: "z3", Skipped: true},
+	}
+
+	b := &bytes.Buffer{}
+	if err := FprintZoneResults(b, "t", results); err == nil {
+		t.Errorf("expected error when a zone failed")
+	}
+
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.HasPrefix(line, "z2 ") && !strings.HasSuffix(line, "apply failed ...") {
+			t.Errorf("expected the first line of the error in the table, got %q", line)
+		}
+	}
+	if !strings.Contains(b.String(), "error of zone (z2):\napply failed\nTASK [foo] fatal\n") {
+		t.Errorf("expected the full error below the table, got:\n%s", b.String())
+	}
+}